@@ -1,8 +1,9 @@
 module whatthecard
 
-go 1.14
+go 1.17
 
 require (
 	github.com/gorilla/mux v1.7.4
 	github.com/gorilla/websocket v1.4.2
+	golang.org/x/text v0.13.0
 )
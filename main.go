@@ -3,7 +3,11 @@ package main
 import (
 	"fmt"
 	"log"
+	"math/rand"
 	"os"
+	"strconv"
+	"time"
+	"whatthecard/pkg/deck"
 	"whatthecard/pkg/game"
 	"whatthecard/pkg/logger"
 	"whatthecard/pkg/server"
@@ -17,11 +21,86 @@ func main() {
 		port = "4000"
 	}
 
-	hub := server.NewHub(logger)
-	gameService := game.NewService(logger)
-	server := server.New(hub, gameService, logger)
+	var store server.RoomStore
+	if dir := os.Getenv("ROOM_STORE_DIR"); dir != "" {
+		fileStore, err := server.NewFileRoomStore(dir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		store = fileStore
+	}
+
+	var decks deck.Store
+	if dir := os.Getenv("DECK_STORE_DIR"); dir != "" {
+		fileStore, err := deck.NewFileStore(dir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		decks = fileStore
+	}
+
+	var contentFilter game.ContentFilter
+	if path := os.Getenv("CONTENT_FILTER_FILE"); path != "" {
+		action := os.Getenv("CONTENT_FILTER_ACTION")
+		if action == "" {
+			action = game.MaskContent
+		}
+		wordList, err := game.LoadWordListFilter(path, action)
+		if err != nil {
+			log.Fatal(err)
+		}
+		contentFilter = wordList
+	}
+
+	roomIDLength := envInt("ROOM_ID_LENGTH", server.DefaultRoomIDLength)
+	roomIDAlphabet := os.Getenv("ROOM_ID_ALPHABET")
+	if roomIDAlphabet == "" {
+		roomIDAlphabet = server.DefaultRoomIDAlphabet
+	}
+	roomIDs, err := server.NewRoomIDGenerator(roomIDLength, roomIDAlphabet, server.DefaultRoomIDBlocklist)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	hubOptions := server.HubOptions{
+		MaxRooms:     envInt("MAX_ROOMS", server.DefaultMaxRooms),
+		EmptyRoomTTL: envDuration("EMPTY_ROOM_TTL", server.DefaultEmptyRoomTTL),
+		IdleRoomTTL:  envDuration("IDLE_ROOM_TTL", server.DefaultIdleRoomTTL),
+	}
+
+	hub := server.NewHub(store, roomIDs, hubOptions, logger)
+	gameService := game.NewService(game.NewRealClock(), rand.NewSource(time.Now().UnixNano()), contentFilter, logger)
+	if err := hub.LoadRooms(gameService); err != nil {
+		log.Fatal(err)
+	}
+	go hub.RunJanitor(time.Minute)
+	server := server.New(hub, gameService, decks, logLevel == "debug", logger)
 
 	if err := server.Start(fmt.Sprintf(":%s", port)); err != nil {
 		log.Fatal(err)
 	}
 }
+
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("%s: %v", key, err)
+	}
+	return n
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("%s: %v", key, err)
+	}
+	return d
+}
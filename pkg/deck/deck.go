@@ -0,0 +1,99 @@
+package deck
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"whatthecard/pkg/game"
+)
+
+const (
+	maxNameLength = 100
+	maxCards      = 1000
+)
+
+// Validation errors
+var (
+	ErrInvalidName  = errors.New("name must have 1 to 100 characters")
+	ErrInvalidCards = errors.New("a deck must have 1 to 1000 cards with a text")
+)
+
+// Deck represents a named list of cards that can be played again in a new room
+type Deck struct {
+	ID        string       `json:"id"`
+	Name      string       `json:"name"`
+	Cards     []*game.Card `json:"cards"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// Info represents a deck without its cards
+type Info struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	NumberOfCards int       `json:"number_of_cards"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Info returns the deck without its cards
+func (d Deck) Info() *Info {
+	return &Info{
+		ID:            d.ID,
+		Name:          d.Name,
+		NumberOfCards: len(d.Cards),
+		UpdatedAt:     d.UpdatedAt,
+	}
+}
+
+// Texts returns the text of every card
+func (d Deck) Texts() []string {
+	texts := make([]string, 0, len(d.Cards))
+	for _, card := range d.Cards {
+		texts = append(texts, card.Text)
+	}
+	return texts
+}
+
+// SetCards validates and replaces the name and the cards, the cards are copied without their game state
+func (d *Deck) SetCards(name string, cards []*game.Card) error {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxNameLength {
+		return ErrInvalidName
+	}
+	if len(cards) == 0 || len(cards) > maxCards {
+		return ErrInvalidCards
+	}
+	copied := make([]*game.Card, 0, len(cards))
+	for _, card := range cards {
+		if card == nil || strings.TrimSpace(card.Text) == "" {
+			return ErrInvalidCards
+		}
+		copied = append(copied, game.NewCard(len(copied)+1, strings.TrimSpace(card.Text), card.Author, 0))
+	}
+	d.Name = name
+	d.Cards = copied
+	return nil
+}
+
+// NewDeck returns a new deck with a random id
+func NewDeck(name string, cards []*game.Card, now time.Time) (*Deck, error) {
+	d := &Deck{
+		ID:        newID(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := d.SetCards(name, cards); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
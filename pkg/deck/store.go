@@ -0,0 +1,118 @@
+package deck
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"whatthecard/pkg/fileutil"
+)
+
+// ErrDeckNotFound occurs when there is no deck with the id
+var ErrDeckNotFound = errors.New("deck not found")
+
+// Store persists decks
+type Store interface {
+	List() ([]*Deck, error)
+	Get(id string) (*Deck, error)
+	Save(d *Deck) error
+	Delete(id string) error
+}
+
+const deckFileExt = ".json"
+
+// FileStore stores each deck as a JSON file in a directory
+type FileStore struct {
+	mu  sync.RWMutex
+	dir string
+}
+
+// NewFileStore returns a new FileStore, the directory is created if it does not exist
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{
+		dir: dir,
+	}, nil
+}
+
+// List reads every deck in the directory sorted by name
+func (s *FileStore) List() ([]*Deck, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	decks := make([]*Deck, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != deckFileExt {
+			continue
+		}
+		d, err := s.read(filepath.Join(s.dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		decks = append(decks, d)
+	}
+	sort.Slice(decks, func(i, j int) bool { return decks[i].Name < decks[j].Name })
+	return decks, nil
+}
+
+// Get reads a deck
+func (s *FileStore) Get(id string) (*Deck, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	d, err := s.read(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, ErrDeckNotFound
+	}
+	return d, err
+}
+
+// Save writes a deck, the previous version is replaced atomically
+func (s *FileStore) Save(d *Deck) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return fileutil.WriteFileAtomic(s.path(d.ID), data)
+}
+
+// Delete deletes a deck
+func (s *FileStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path(id))
+	if os.IsNotExist(err) {
+		return ErrDeckNotFound
+	}
+	return err
+}
+
+func (s *FileStore) read(path string) (*Deck, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	d := &Deck{}
+	if err := json.Unmarshal(data, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, filepath.Base(id)+deckFileExt)
+}
@@ -1,23 +1,29 @@
 package game
 
-import (
-	"math/rand"
-	"time"
+import "math/rand"
+
+// Card outcomes
+const (
+	GuessedOutcome = "guessed"
+	SkippedOutcome = "skipped"
 )
 
 // Card represents a card
 type Card struct {
-	ID     int    `json:"id"`
-	Text   string `json:"text"`
-	Author string `json:"author"`
+	ID       int    `json:"id"`
+	Text     string `json:"text"`
+	Author   string `json:"author"`
+	AuthorID int    `json:"author_id"`
+	Outcome  string `json:"outcome,omitempty"`
 }
 
 // NewCard returns a new Card
-func NewCard(id int, text, author string) *Card {
+func NewCard(id int, text, author string, authorID int) *Card {
 	return &Card{
-		ID:     id,
-		Text:   text,
-		Author: author,
+		ID:       id,
+		Text:     text,
+		Author:   author,
+		AuthorID: authorID,
 	}
 }
 
@@ -40,10 +46,8 @@ func (p *Pile) Reset() {
 	p.lastCardID = 0
 }
 
-// Shuffle shuffles cards in the pile
-func (p *Pile) Shuffle() {
-	s := rand.NewSource(time.Now().UnixNano())
-	r := rand.New(s)
+// Shuffle shuffles cards in the pile with the given random source
+func (p *Pile) Shuffle(r *rand.Rand) {
 	r.Shuffle(len(p.Cards), func(i, j int) { p.Cards[i], p.Cards[j] = p.Cards[j], p.Cards[i] })
 }
 
@@ -62,6 +66,32 @@ func (p *Pile) Pop() *Card {
 	return card
 }
 
+// Find returns a card with the given id in the pile
+func (p Pile) Find(id int) *Card {
+	for _, card := range p.Cards {
+		if card.ID == id {
+			return card
+		}
+	}
+	return nil
+}
+
+// Remove removes a card with the given id from the pile
+func (p *Pile) Remove(id int) *Card {
+	for i, card := range p.Cards {
+		if card.ID == id {
+			p.Cards = append(p.Cards[:i], p.Cards[i+1:]...)
+			return card
+		}
+	}
+	return nil
+}
+
+// PutBack puts a card back to the bottom of the pile without changing its id
+func (p *Pile) PutBack(card *Card) {
+	p.Cards = append([]*Card{card}, p.Cards...)
+}
+
 // Push pushs a card to the top of the pile
 func (p *Pile) Push(card *Card) {
 	p.lastCardID++
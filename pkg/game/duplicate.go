@@ -0,0 +1,149 @@
+package game
+
+import "fmt"
+
+// Duplicate policies decide what happens to a card that has already been submitted
+const (
+	// RejectDuplicates rejects the card with DuplicateCardErr
+	RejectDuplicates = "reject"
+	// MergeDuplicates counts the card as submitted without adding it to the draw pile
+	MergeDuplicates = "merge"
+	// FlagDuplicates adds the card and flags it to the host
+	FlagDuplicates = "flag"
+)
+
+const maxNearDuplicateDistance = 3
+
+// DuplicateFlag represents a card flagged as a duplicate of another card
+type DuplicateFlag struct {
+	CardID          int    `json:"card_id"`
+	Text            string `json:"text"`
+	DuplicateOfID   int    `json:"duplicate_of_id"`
+	DuplicateOfText string `json:"duplicate_of_text"`
+	Exact           bool   `json:"exact"`
+}
+
+func isValidDuplicatePolicy(policy string) bool {
+	return policy == RejectDuplicates || policy == MergeDuplicates || policy == FlagDuplicates
+}
+
+// findDuplicate returns a card in the draw pile with the same text regardless of case and spacing,
+// otherwise a card whose text is within the near duplicate distance, the card excludeID is skipped
+func (g Game) findDuplicate(text string, excludeID int) (card *Card, exact bool) {
+	key := []rune(cardKey(text))
+	var near *Card
+	nearest := nearDuplicateDistance(len(key)) + 1
+	for _, c := range g.DrawPile.Cards {
+		if c.ID == excludeID {
+			continue
+		}
+		k := []rune(cardKey(c.Text))
+		if string(k) == string(key) {
+			return c, true
+		}
+		if d := editDistance(key, k); d < nearest && d <= nearDuplicateDistance(len(k)) {
+			near, nearest = c, d
+		}
+	}
+	return near, false
+}
+
+// nearDuplicateDistance returns how many edits make a text of n characters a near duplicate
+// short texts are only compared exactly because a single edit often makes another word
+func nearDuplicateDistance(n int) int {
+	d := n / 8
+	if d > maxNearDuplicateDistance {
+		return maxNearDuplicateDistance
+	}
+	return d
+}
+
+// editDistance returns the Levenshtein distance between two texts
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// MergeCard counts a card as submitted by the player without adding it to the draw pile
+func (g *Game) MergeCard(playerID int) {
+	player, ok := g.Players[playerID]
+	if !ok {
+		return
+	}
+	player.NumberOfSubmittedCards++
+	g.playIfAllSubmitted()
+}
+
+// mergeEditedCard removes an edited card that duplicates another card from the draw pile
+// the card stays counted as submitted by its author
+func (g *Game) mergeEditedCard(cardID int) {
+	if g.DrawPile.Remove(cardID) == nil {
+		return
+	}
+	g.unflagCard(cardID)
+	g.unflagContent(cardID)
+}
+
+// flagDuplicate flags the card to the host as a duplicate of another card
+func (g *Game) flagDuplicate(card, duplicateOf *Card, exact bool) {
+	g.DuplicateFlags = append(g.DuplicateFlags, &DuplicateFlag{
+		CardID:          card.ID,
+		Text:            card.Text,
+		DuplicateOfID:   duplicateOf.ID,
+		DuplicateOfText: duplicateOf.Text,
+		Exact:           exact,
+	})
+}
+
+// unflagCard removes every flag of the card
+func (g *Game) unflagCard(cardID int) {
+	flags := make([]*DuplicateFlag, 0, len(g.DuplicateFlags))
+	for _, flag := range g.DuplicateFlags {
+		if flag.CardID != cardID && flag.DuplicateOfID != cardID {
+			flags = append(flags, flag)
+		}
+	}
+	g.DuplicateFlags = flags
+}
+
+// DuplicateCardErr occurs when a card has already been submitted and duplicates are rejected
+type DuplicateCardErr struct {
+	cmd   Command
+	exact bool
+}
+
+func (e DuplicateCardErr) Error() string {
+	if e.exact {
+		return fmt.Sprintf("cmd: %s: the card has already been submitted", e.cmd.Name)
+	}
+	return fmt.Sprintf("cmd: %s: a similar card has already been submitted", e.cmd.Name)
+}
+
+func (e DuplicateCardErr) Code() string {
+	return "duplicate_card"
+}
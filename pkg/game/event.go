@@ -0,0 +1,168 @@
+package game
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// SystemPlayerID is the player id of commands issued by the server instead of a player
+const SystemPlayerID = 0
+
+// systemCommands can only be executed by the server
+var systemCommands = map[string]bool{
+	"add_player":        true,
+	"remove_player":     true,
+	"connect_player":    true,
+	"disconnect_player": true,
+	"expire_turn":       true,
+}
+
+// Event represents an accepted command in the game log
+type Event struct {
+	Seq      int             `json:"seq"`
+	Time     time.Time       `json:"time"`
+	Name     string          `json:"name"`
+	PlayerID int             `json:"player_id"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+}
+
+// newPayload returns an empty payload of the command, ok is false if the command does not exist
+func newPayload(name string) (payload interface{}, ok bool) {
+	switch name {
+	case "set_cards_per_player":
+		return &SetCardPerPlayerPayload{}, true
+	case "add_player":
+		return &AddPlayerPayload{}, true
+	case "remove_player":
+		return &RemovePlayerPayload{}, true
+	case "connect_player", "disconnect_player":
+		return &PlayerPayload{}, true
+	case "add_card":
+		return &AddCardPayload{}, true
+	case "export":
+		return &ExportPayload{}, true
+	case "set_card_length":
+		return &SetCardLengthPayload{}, true
+	case "set_content_filter":
+		return &SetContentFilterPayload{}, true
+	case "set_duplicate_policy":
+		return &SetDuplicatePolicyPayload{}, true
+	case "import_cards":
+		return &ImportCardsPayload{}, true
+	case "edit_card":
+		return &EditCardPayload{}, true
+	case "delete_card":
+		return &DeleteCardPayload{}, true
+	case "kick_player":
+		return &KickPlayerPayload{}, true
+	case "ban_player":
+		return &BanPlayerPayload{}, true
+	case "transfer_host":
+		return &TransferHostPayload{}, true
+	case "reset":
+		return &ResetPayload{}, true
+	case "set_turn_order":
+		return &SetTurnOrderPayload{}, true
+	case "create_team":
+		return &CreateTeamPayload{}, true
+	case "rename_team":
+		return &RenameTeamPayload{}, true
+	case "assign_team":
+		return &AssignTeamPayload{}, true
+	case "balance_teams":
+		return &BalanceTeamsPayload{}, true
+	case "set_rounds":
+		return &SetRoundsPayload{}, true
+	case "set_turn_duration":
+		return &SetTurnDurationPayload{}, true
+	case "start", "end_game", "undo", "draw_card", "end_turn", "guess_card", "skip_card", "pass_card", "expire_turn":
+		return nil, true
+	default:
+		return nil, false
+	}
+}
+
+// NewCommand decodes a command with its JSON payload
+func NewCommand(name string, playerID int, payload json.RawMessage) (Command, error) {
+	cmd := Command{
+		Name:     name,
+		PlayerID: playerID,
+	}
+	p, ok := newPayload(name)
+	if !ok {
+		return cmd, UnknownCommandErr{cmd: cmd}
+	}
+	if p == nil {
+		return cmd, nil
+	}
+	if err := json.Unmarshal(payload, p); err != nil {
+		return cmd, InvalidCommandErr{cmd: cmd}
+	}
+	cmd.Payload = p
+	return cmd, nil
+}
+
+// IsSystemCommand checks if the command can only be executed by the server
+func IsSystemCommand(name string) bool {
+	return systemCommands[name]
+}
+
+func (g *Game) appendEvent(cmd Command) {
+	event := &Event{
+		Seq:      len(g.Events) + 1,
+		Time:     g.clock.Now(),
+		Name:     cmd.Name,
+		PlayerID: cmd.PlayerID,
+	}
+	if cmd.Payload != nil {
+		payload, err := json.Marshal(cmd.Payload)
+		if err != nil {
+			g.logger.Errorf("failed to log cmd: %s: %v", cmd.Name, err)
+		}
+		event.Payload = payload
+	}
+	g.Events = append(g.Events, event)
+}
+
+// replayClock is a Clock that tells the time of the event being replayed
+type replayClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *replayClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *replayClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+func (c *replayClock) set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Replay rebuilds a game by executing every event of the log in order on a new game with the given seed
+func (s *Service) Replay(roomID string, seed int64, events []*Event) (*Game, error) {
+	clock := &replayClock{}
+	g := s.NewGameWithSeed(seed)
+	g.RoomID = roomID
+	g.clock = clock
+	for _, event := range events {
+		cmd, err := NewCommand(event.Name, event.PlayerID, event.Payload)
+		if err != nil {
+			return nil, ReplayErr{event: event, err: err}
+		}
+		clock.set(event.Time)
+		if err := g.ExecCommand(cmd); err != nil {
+			return nil, ReplayErr{event: event, err: err}
+		}
+	}
+	g.clock = s.clock
+	return g, nil
+}
@@ -0,0 +1,91 @@
+package game
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// ExportedCard represents a card with the first time it was drawn
+// draw order is 0 and drawn by is empty if the card has never been drawn
+type ExportedCard struct {
+	ID        int    `json:"id"`
+	Text      string `json:"text"`
+	Author    string `json:"author"`
+	AuthorID  int    `json:"author_id"`
+	DrawOrder int    `json:"draw_order"`
+	DrawnBy   string `json:"drawn_by"`
+	DrawnByID int    `json:"drawn_by_id"`
+	Outcome   string `json:"outcome"`
+}
+
+// ExportCards returns every card of the game in the order they were first drawn, cards never drawn come last
+func (g Game) ExportCards() []*ExportedCard {
+	cards := make([]*ExportedCard, 0)
+	exported := make(map[int]*ExportedCard)
+	add := func(pile []*Card) {
+		for _, card := range pile {
+			c := &ExportedCard{
+				ID:       card.ID,
+				Text:     card.Text,
+				Author:   card.Author,
+				AuthorID: card.AuthorID,
+				Outcome:  card.Outcome,
+			}
+			exported[card.ID] = c
+			cards = append(cards, c)
+		}
+	}
+	add(g.DrawPile.Cards)
+	add(g.DiscardPile.Cards)
+	for _, player := range g.Players {
+		add(player.Hand)
+	}
+
+	order := 0
+	for _, draw := range g.Draws {
+		c, ok := exported[draw.CardID]
+		if !ok || c.DrawOrder > 0 {
+			continue
+		}
+		order++
+		c.DrawOrder = order
+		c.DrawnBy = draw.PlayerName
+		c.DrawnByID = draw.PlayerID
+	}
+
+	sort.Slice(cards, func(i, j int) bool {
+		if (cards[i].DrawOrder == 0) != (cards[j].DrawOrder == 0) {
+			return cards[j].DrawOrder == 0
+		}
+		if cards[i].DrawOrder != cards[j].DrawOrder {
+			return cards[i].DrawOrder < cards[j].DrawOrder
+		}
+		return cards[i].ID < cards[j].ID
+	})
+	return cards
+}
+
+// WriteCardsCSV writes exported cards as CSV with a header
+func WriteCardsCSV(w io.Writer, cards []*ExportedCard) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "text", "author", "draw_order", "drawn_by", "outcome"}); err != nil {
+		return err
+	}
+	for _, card := range cards {
+		record := []string{
+			strconv.Itoa(card.ID),
+			card.Text,
+			card.Author,
+			strconv.Itoa(card.DrawOrder),
+			card.DrawnBy,
+			card.Outcome,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
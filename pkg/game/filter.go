@@ -0,0 +1,169 @@
+package game
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+)
+
+// Content filter actions decide what happens to a text containing a blocked word
+const (
+	// RejectContent rejects the text with ContentRejectedErr
+	RejectContent = "reject"
+	// MaskContent replaces every blocked word with asterisks
+	MaskContent = "mask"
+	// FlagContent keeps the text and flags it to the host
+	FlagContent = "flag"
+)
+
+// ErrInvalidFilterAction occurs when a content filter is created with an unknown action
+var ErrInvalidFilterAction = errors.New("content filter action must be reject, mask or flag")
+
+// ContentFilter screens card texts and player names
+type ContentFilter interface {
+	// Filter returns the text to keep and the action to take, the action is empty if the text is clean
+	Filter(text string) (filtered string, action string)
+}
+
+// ContentFlag represents a card or a player name flagged by the content filter
+// card id is 0 if the player name has been flagged
+type ContentFlag struct {
+	PlayerID int    `json:"player_id"`
+	CardID   int    `json:"card_id"`
+	Text     string `json:"text"`
+}
+
+// WordListFilter is a ContentFilter that blocks the words of a list regardless of case
+type WordListFilter struct {
+	words  map[string]bool
+	action string
+}
+
+// NewWordListFilter returns a new WordListFilter taking the action on texts containing any of the words
+func NewWordListFilter(words []string, action string) (*WordListFilter, error) {
+	if action != RejectContent && action != MaskContent && action != FlagContent {
+		return nil, ErrInvalidFilterAction
+	}
+	f := &WordListFilter{
+		words:  make(map[string]bool),
+		action: action,
+	}
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			f.words[cases.Fold().String(word)] = true
+		}
+	}
+	return f, nil
+}
+
+// LoadWordListFilter returns a new WordListFilter with the words of a file
+// the file has one word per line, blank lines and lines starting with # are skipped
+func LoadWordListFilter(path string, action string) (*WordListFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	words := make([]string, 0)
+	s := bufio.NewScanner(file)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return NewWordListFilter(words, action)
+}
+
+// Filter finds the blocked words of the text, only whole words made of letters and digits are matched
+func (f *WordListFilter) Filter(text string) (string, string) {
+	runes := []rune(text)
+	matched := false
+	start := -1
+	for i := 0; i <= len(runes); i++ {
+		if i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start < 0 {
+			continue
+		}
+		if f.words[cases.Fold().String(string(runes[start:i]))] {
+			matched = true
+			if f.action == MaskContent {
+				for j := start; j < i; j++ {
+					runes[j] = '*'
+				}
+			}
+		}
+		start = -1
+	}
+	if !matched {
+		return text, ""
+	}
+	return string(runes), f.action
+}
+
+// FilterText screens the text with the content filter of the game if it is enabled
+func (g Game) FilterText(text string) (string, string) {
+	if g.contentFilter == nil || !g.ContentFilterEnabled {
+		return text, ""
+	}
+	return g.contentFilter.Filter(text)
+}
+
+// flagContent flags the text written by the player to the host
+func (g *Game) flagContent(playerID, cardID int, text string) {
+	g.ContentFlags = append(g.ContentFlags, &ContentFlag{
+		PlayerID: playerID,
+		CardID:   cardID,
+		Text:     text,
+	})
+}
+
+// unflagContent removes every flag of the card, flags of player names are removed with card id 0
+func (g *Game) unflagContent(cardID int) {
+	flags := make([]*ContentFlag, 0, len(g.ContentFlags))
+	for _, flag := range g.ContentFlags {
+		if flag.CardID != cardID {
+			flags = append(flags, flag)
+		}
+	}
+	g.ContentFlags = flags
+}
+
+// unflagCards removes the flags of every card, flags of player names are kept
+func (g *Game) unflagCards() {
+	flags := make([]*ContentFlag, 0, len(g.ContentFlags))
+	for _, flag := range g.ContentFlags {
+		if flag.CardID == 0 {
+			flags = append(flags, flag)
+		}
+	}
+	g.ContentFlags = flags
+}
+
+// ContentRejectedErr occurs when a text is rejected by the content filter
+type ContentRejectedErr struct {
+	cmd Command
+}
+
+func (e ContentRejectedErr) Error() string {
+	return fmt.Sprintf("cmd: %s: the text is not allowed", e.cmd.Name)
+}
+
+func (e ContentRejectedErr) Code() string {
+	return "content_rejected"
+}
@@ -1,12 +1,20 @@
 package game
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
 	"whatthecard/pkg/logger"
 )
 
-const maxCardsPerPlayer = 20
+const (
+	maxCardsPerPlayer = 20
+	maxTurnDuration   = 10 * time.Minute
+)
 
 // Phase represents a game phase
 type Phase int
@@ -16,6 +24,7 @@ const (
 	WaitingPhase Phase = iota
 	SubmitPhase
 	PlayPhase
+	FinishedPhase
 )
 
 func (p Phase) String() string {
@@ -26,6 +35,8 @@ func (p Phase) String() string {
 		return "SUBMIT_PHASE"
 	case PlayPhase:
 		return "PLAY_PHASE"
+	case FinishedPhase:
+		return "FINISHED_PHASE"
 	default:
 		return ""
 	}
@@ -42,31 +53,89 @@ type Game struct {
 	HostID           int
 	LastDrawPlayerID int
 	CardsPerPlayer   int
-	logger           *logger.Logger
+	CardValidator    CardValidator
+	DuplicatePolicy  string
+	DuplicateFlags   []*DuplicateFlag
+	// ContentFilterEnabled turns the content filter of the service on for this game
+	ContentFilterEnabled bool
+	ContentFlags         []*ContentFlag
+	TurnOrder            []int
+	Turn                 int
+	Teams                map[int]*Team
+	lastTeamID           int
+	Rounds               []*Round
+	Round                int
+	TurnDuration         time.Duration
+	TurnDeadline         time.Time
+	BannedNames          map[string]bool
+	BannedTokens         map[string]bool
+	Seed                 int64
+	Shuffles             int
+	Draws                []*Draw
+	Events               []*Event `json:"-"`
+	history              [][]byte
+	contentFilter        ContentFilter
+	clock                Clock
+	logger               *logger.Logger
 }
 
-// NewGame returns a new Game
-func NewGame(logger *logger.Logger) *Game {
+// NewGame returns a new Game shuffled by a random source seeded with the given seed
+func NewGame(seed int64, logger *logger.Logger) *Game {
 	return &Game{
-		Phase:          WaitingPhase,
-		Players:        make(map[int]*Player),
-		DrawPile:       NewPile(),
-		DiscardPile:    NewPile(),
-		CardsPerPlayer: 5,
-		logger:         logger,
+		Phase:                WaitingPhase,
+		Players:              make(map[int]*Player),
+		DrawPile:             NewPile(),
+		DiscardPile:          NewPile(),
+		CardsPerPlayer:       5,
+		CardValidator:        DefaultCardValidator(),
+		DuplicatePolicy:      RejectDuplicates,
+		ContentFilterEnabled: true,
+		TurnOrder:            make([]int, 0),
+		Teams:                make(map[int]*Team),
+		Rounds:               DefaultRounds(),
+		BannedNames:          make(map[string]bool),
+		BannedTokens:         make(map[string]bool),
+		Seed:                 seed,
+		Events:               make([]*Event, 0),
+		clock:                NewRealClock(),
+		logger:               logger,
 	}
 }
 
 // State represents a game state
 type State struct {
-	Phase            string    `json:"phase"`
-	DrawPileLeft     int       `json:"draw_pile_left"`
-	DiscardCards     []*Card   `json:"discard_cards"`
-	CardsPerPlayer   int       `json:"cards_per_player"`
-	PlayerID         int       `json:"player_id"`
-	HostID           int       `json:"host_id"`
-	Players          []*Player `json:"players"`
-	LastDrawPlayerID int       `json:"last_draw_player_id"`
+	Phase                string           `json:"phase"`
+	DrawPileLeft         int              `json:"draw_pile_left"`
+	DiscardCards         []*Card          `json:"discard_cards"`
+	CardsPerPlayer       int              `json:"cards_per_player"`
+	PlayerID             int              `json:"player_id"`
+	HostID               int              `json:"host_id"`
+	Players              []*PlayerState   `json:"players"`
+	Hand                 []*Card          `json:"hand"`
+	SessionToken         string           `json:"session_token,omitempty"`
+	Spectator            bool             `json:"spectator"`
+	Spectators           []*Spectator     `json:"spectators"`
+	MyCards              []*Card          `json:"my_cards"`
+	LastDrawPlayerID     int              `json:"last_draw_player_id"`
+	TurnOrder            []int            `json:"turn_order"`
+	CurrentPlayerID      int              `json:"current_player_id"`
+	NextPlayerID         int              `json:"next_player_id"`
+	Teams                []*Team          `json:"teams"`
+	Rounds               []*Round         `json:"rounds"`
+	Round                int              `json:"round"`
+	Summary              *Summary         `json:"summary,omitempty"`
+	TurnDuration         int              `json:"turn_duration"`
+	TurnTimeLeft         int              `json:"turn_time_left"`
+	CanUndo              bool             `json:"can_undo"`
+	DuplicatePolicy      string           `json:"duplicate_policy"`
+	DuplicateFlags       []*DuplicateFlag `json:"duplicate_flags,omitempty"`
+	ContentFilterEnabled bool             `json:"content_filter_enabled"`
+	ContentFlags         []*ContentFlag   `json:"content_flags,omitempty"`
+}
+
+// Clock returns the clock used by the game
+func (g Game) Clock() Clock {
+	return g.clock
 }
 
 // SetCardsPerPlayer resets the game and sets a number of cards per player
@@ -81,22 +150,82 @@ func (g *Game) SetCardsPerPlayer(n int) {
 func (g *Game) AddPlayer(id int, name string) *Player {
 	p := NewPlayer(id, name)
 	g.Players[p.ID] = p
+	g.TurnOrder = append(g.TurnOrder, p.ID)
+	if id > g.lastPlayerID {
+		g.lastPlayerID = id
+	}
 	g.logger.Debugf("player %d has joined the room %s", id, g.RoomID)
 
-	if len(g.Players) == 1 {
+	if _, ok := g.Players[g.HostID]; !ok {
 		g.PromoteHost(p.ID)
 	}
 
 	return p
 }
 
+// LastPlayerID returns the highest id ever given to a player, including removed players
+func (g Game) LastPlayerID() int {
+	return g.lastPlayerID
+}
+
+// PlayerByToken returns a player with the given session token
+func (g Game) PlayerByToken(token string) *Player {
+	if token == "" {
+		return nil
+	}
+	for _, player := range g.Players {
+		if player.Token == token {
+			return player
+		}
+	}
+	return nil
+}
+
+// DisconnectPlayer marks a player as disconnected, the player keeps their seat until removed
+// the turn is passed if it is the player's turn
+func (g *Game) DisconnectPlayer(id int) {
+	player, ok := g.Players[id]
+	if !ok || !player.Connected {
+		return
+	}
+	isCurrentPlayer := id == g.CurrentPlayerID()
+	if isCurrentPlayer {
+		g.returnHand(id)
+	}
+	player.Connected = false
+	player.DisconnectedAt = g.clock.Now()
+	if isCurrentPlayer {
+		g.NextTurn()
+	}
+	g.logger.Debugf("player %d has disconnected from the room %s", id, g.RoomID)
+	if g.Phase == SubmitPhase {
+		g.playIfAllSubmitted()
+	}
+}
+
+// ReconnectPlayer marks a disconnected player as connected
+func (g *Game) ReconnectPlayer(id int) {
+	player, ok := g.Players[id]
+	if !ok {
+		return
+	}
+	player.Connected = true
+	player.DisconnectedAt = time.Time{}
+	g.logger.Debugf("player %d has reconnected to the room %s", id, g.RoomID)
+}
+
 // RemovePlayer removes a player from a game
 func (g *Game) RemovePlayer(id int) {
 	_, ok := g.Players[id]
 	if !ok {
 		return
 	}
+	if id == g.CurrentPlayerID() {
+		g.TurnDeadline = time.Time{}
+	}
+	g.returnHand(id)
 	delete(g.Players, id)
+	g.removeFromTurnOrder(id)
 	g.logger.Debugf("player %d has left the room %s", id, g.RoomID)
 
 	if len(g.Players) > 0 && g.isHost(id) {
@@ -106,8 +235,20 @@ func (g *Game) RemovePlayer(id int) {
 				minID = k
 			}
 		}
+		minConnectedID := math.MaxInt32
+		for k := range g.Players {
+			if k < minConnectedID && g.isConnected(k) {
+				minConnectedID = k
+			}
+		}
+		if minConnectedID != math.MaxInt32 {
+			minID = minConnectedID
+		}
 		g.PromoteHost(minID)
 	}
+	if g.Phase == SubmitPhase {
+		g.playIfAllSubmitted()
+	}
 }
 
 // PromoteHost promotes a player to a host
@@ -116,41 +257,433 @@ func (g *Game) PromoteHost(playerID int) {
 	g.logger.Debugf("player %d has been promoted to a host", playerID)
 }
 
+// KickPlayer removes a player from the game, the player can join again as a new player
+func (g *Game) KickPlayer(id int) {
+	g.logger.Debugf("player %d has been kicked from the room %s", id, g.RoomID)
+	g.RemovePlayer(id)
+}
+
+// BanPlayer removes a player from the game and prevents their name and session from joining again
+func (g *Game) BanPlayer(id int) {
+	player, ok := g.Players[id]
+	if !ok {
+		return
+	}
+	g.BannedNames[normalizeName(player.Name)] = true
+	g.BannedTokens[player.Token] = true
+	g.logger.Debugf("player %d has been banned from the room %s", id, g.RoomID)
+	g.RemovePlayer(id)
+}
+
+// IsBanned checks if a player name or session token has been banned
+func (g Game) IsBanned(name, token string) bool {
+	return (name != "" && g.BannedNames[normalizeName(name)]) || (token != "" && g.BannedTokens[token])
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
 func (g *Game) isHost(playerID int) bool {
 	return playerID == g.HostID
 }
 
-// DrawCard draws a card
+// SetTurnOrder sets the order in which players take turns
+// the turn stays with the current player if the game is already being played
+func (g *Game) SetTurnOrder(playerIDs []int) {
+	currentPlayerID := g.CurrentPlayerID()
+	g.TurnOrder = append([]int{}, playerIDs...)
+	g.Turn = 0
+	for i, id := range g.TurnOrder {
+		if id == currentPlayerID {
+			g.Turn = i
+			break
+		}
+	}
+}
+
+// CurrentPlayerID returns an id of the player who has the turn
+func (g Game) CurrentPlayerID() int {
+	if len(g.TurnOrder) == 0 {
+		return 0
+	}
+	if g.isConnected(g.TurnOrder[g.Turn]) {
+		return g.TurnOrder[g.Turn]
+	}
+	i := g.turnAfter(g.Turn)
+	if !g.isConnected(g.TurnOrder[i]) {
+		return 0
+	}
+	return g.TurnOrder[i]
+}
+
+// NextPlayerID returns an id of the player who will get the next turn
+func (g Game) NextPlayerID() int {
+	currentPlayerID := g.CurrentPlayerID()
+	if currentPlayerID == 0 {
+		return 0
+	}
+	for i, id := range g.TurnOrder {
+		if id == currentPlayerID {
+			return g.TurnOrder[g.turnAfter(i)]
+		}
+	}
+	return 0
+}
+
+// NextTurn passes the turn to the next connected player
+func (g *Game) NextTurn() {
+	if len(g.TurnOrder) == 0 {
+		return
+	}
+	g.Turn = g.turnAfter(g.Turn)
+	g.TurnDeadline = time.Time{}
+	g.logger.Debugf("player %d has the turn in the room %s", g.CurrentPlayerID(), g.RoomID)
+}
+
+// turnAfter returns the turn index of the first connected player after the given index
+func (g Game) turnAfter(i int) int {
+	n := len(g.TurnOrder)
+	for k := 1; k <= n; k++ {
+		j := (i + k) % n
+		if g.isConnected(g.TurnOrder[j]) {
+			return j
+		}
+	}
+	return i
+}
+
+func (g Game) isConnected(playerID int) bool {
+	player, ok := g.Players[playerID]
+	return ok && player.Connected
+}
+
+func (g *Game) removeFromTurnOrder(playerID int) {
+	for i, id := range g.TurnOrder {
+		if id != playerID {
+			continue
+		}
+		g.TurnOrder = append(g.TurnOrder[:i], g.TurnOrder[i+1:]...)
+		if i < g.Turn {
+			g.Turn--
+		}
+		break
+	}
+	if g.Turn >= len(g.TurnOrder) {
+		g.Turn = 0
+	}
+}
+
+// isValidTurnOrder checks if the given ids contain every player exactly once
+func (g Game) isValidTurnOrder(playerIDs []int) bool {
+	if len(playerIDs) != len(g.Players) {
+		return false
+	}
+	seen := make(map[int]bool, len(playerIDs))
+	for _, id := range playerIDs {
+		if _, ok := g.Players[id]; !ok || seen[id] {
+			return false
+		}
+		seen[id] = true
+	}
+	return true
+}
+
+// DrawCard draws a card to the player's hand
 func (g *Game) DrawCard(playerID int) *Card {
+	player, ok := g.Players[playerID]
+	if !ok || g.DrawPile.Len() == 0 {
+		return nil
+	}
 	g.LastDrawPlayerID = playerID
+	if g.TurnDuration > 0 && g.TurnDeadline.IsZero() {
+		g.TurnDeadline = g.clock.Now().Add(g.TurnDuration)
+	}
 	card := g.DrawPile.Pop()
+	player.Hand = append(player.Hand, card)
+	g.recordDraw(player, card)
+	return card
+}
+
+// discardHeldCard moves the card in the player's hand to the discard pile
+func (g *Game) discardHeldCard(playerID int, outcome string) *Card {
+	player, ok := g.Players[playerID]
+	if !ok || len(player.Hand) == 0 {
+		return nil
+	}
+	card := player.Hand[0]
+	player.Hand = player.Hand[1:]
+	card.Outcome = outcome
 	g.DiscardPile.Cards = append(g.DiscardPile.Cards, card)
+	g.resolveDraw(card.ID, outcome)
+	return card
+}
+
+// GuessCard discards the card in the player's hand as guessed and awards a point to the player's team
+func (g *Game) GuessCard(playerID int) *Card {
+	card := g.discardHeldCard(playerID, GuessedOutcome)
+	if card == nil {
+		return nil
+	}
+	if player, ok := g.Players[playerID]; ok {
+		player.Score++
+	}
+	if team := g.playerTeam(playerID); team != nil {
+		team.AddScore(g.Round)
+	}
+	g.advanceRoundIfDone()
+	return card
+}
+
+// SkipCard discards the card in the player's hand as skipped
+func (g *Game) SkipCard(playerID int) *Card {
+	card := g.discardHeldCard(playerID, SkippedOutcome)
+	if card == nil {
+		return nil
+	}
+	g.advanceRoundIfDone()
+	return card
+}
+
+// PassCard puts the card in the player's hand back to the draw pile
+func (g *Game) PassCard(playerID int) *Card {
+	player, ok := g.Players[playerID]
+	if !ok || len(player.Hand) == 0 {
+		return nil
+	}
+	card := player.Hand[0]
+	player.Hand = player.Hand[1:]
+	g.DrawPile.PutBack(card)
+	g.resolveDraw(card.ID, PassedOutcome)
 	return card
 }
 
+func (g Game) hasCardInHand(playerID int) bool {
+	player, ok := g.Players[playerID]
+	return ok && len(player.Hand) > 0
+}
+
+func (g Game) cardsInHands() int {
+	n := 0
+	for _, player := range g.Players {
+		n += len(player.Hand)
+	}
+	return n
+}
+
+// SetTurnDuration sets how long a turn lasts, 0 means a turn lasts until the player ends it
+func (g *Game) SetTurnDuration(d time.Duration) {
+	if d < 0 || d > maxTurnDuration {
+		return
+	}
+	g.TurnDuration = d
+}
+
+// TurnTimeLeft returns the remaining time of the current turn
+func (g Game) TurnTimeLeft() time.Duration {
+	if g.TurnDeadline.IsZero() {
+		return 0
+	}
+	left := g.TurnDeadline.Sub(g.clock.Now())
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+// ExpireTurn returns the cards in the current player's hand to the draw pile and passes the turn
+func (g *Game) ExpireTurn() {
+	playerID := g.CurrentPlayerID()
+	g.logger.Debugf("turn of player %d has expired in the room %s", playerID, g.RoomID)
+	g.returnHand(playerID)
+	g.NextTurn()
+}
+
+// returnHand puts every card in the player's hand back to the draw pile
+func (g *Game) returnHand(playerID int) {
+	player, ok := g.Players[playerID]
+	if !ok {
+		return
+	}
+	for _, card := range player.Hand {
+		g.DrawPile.PutBack(card)
+		g.resolveDraw(card.ID, ReturnedOutcome)
+	}
+	player.Hand = nil
+}
+
+// SetRounds sets the rounds to be played
+func (g *Game) SetRounds(rounds []*Round) {
+	if !isValidRounds(rounds) {
+		return
+	}
+	g.Rounds = rounds
+	g.Round = 0
+}
+
+// advanceRoundIfDone moves to the next round once every card of the current round has been played
+// the game is finished after the last round
+func (g *Game) advanceRoundIfDone() {
+	if g.Phase != PlayPhase || g.DrawPile.Len() > 0 || g.cardsInHands() > 0 {
+		return
+	}
+	if g.Round+1 >= len(g.Rounds) {
+		g.EndGame()
+		return
+	}
+	g.Round++
+	g.recyclePiles()
+	g.NextTurn()
+	g.logger.Debugf("round %d has started in the room %s", g.Round+1, g.RoomID)
+}
+
+// recyclePiles puts every discarded and held card back to the draw pile then shuffles it
+func (g *Game) recyclePiles() {
+	for id := range g.Players {
+		g.returnHand(id)
+	}
+	for _, card := range g.DiscardPile.Cards {
+		card.Outcome = ""
+	}
+	g.DrawPile.Cards = append(g.DrawPile.Cards, g.DiscardPile.Cards...)
+	g.DiscardPile.Reset()
+	g.shuffleDrawPile()
+}
+
+// shuffleDrawPile shuffles the draw pile with a source seeded by the game seed and the number of previous shuffles,
+// a restored game keeps shuffling like the game would have without a restart
+func (g *Game) shuffleDrawPile() {
+	g.DrawPile.Shuffle(rand.New(rand.NewSource(g.Seed + int64(g.Shuffles))))
+	g.Shuffles++
+}
+
+func (g *Game) resetScores() {
+	g.Round = 0
+	for _, player := range g.Players {
+		player.Score = 0
+	}
+	for _, team := range g.Teams {
+		team.Score = 0
+		team.RoundScores = nil
+	}
+}
+
+// CreateTeam creates a new team
+func (g *Game) CreateTeam(name string) *Team {
+	g.lastTeamID++
+	if name == "" {
+		name = fmt.Sprintf("Team %d", g.lastTeamID)
+	}
+	team := NewTeam(g.lastTeamID, name)
+	g.Teams[team.ID] = team
+	g.logger.Debugf("team %d has been created in the room %s", team.ID, g.RoomID)
+	return team
+}
+
+// RenameTeam renames a team
+func (g *Game) RenameTeam(teamID int, name string) {
+	team, ok := g.Teams[teamID]
+	if !ok || name == "" {
+		return
+	}
+	team.Name = name
+}
+
+// AssignTeam assigns a player to a team, team id 0 removes the player from their team
+func (g *Game) AssignTeam(playerID, teamID int) {
+	player, ok := g.Players[playerID]
+	if !ok {
+		return
+	}
+	if _, ok := g.Teams[teamID]; !ok && teamID != 0 {
+		return
+	}
+	player.TeamID = teamID
+}
+
+// BalanceTeams spreads players evenly across n teams and interleaves the turn order by team
+// existing teams are reused and missing teams are created, n <= 0 uses the existing teams or two teams if there is none
+func (g *Game) BalanceTeams(n int) {
+	if n <= 0 {
+		n = len(g.Teams)
+	}
+	if n <= 0 {
+		n = 2
+	}
+	for len(g.Teams) < n {
+		g.CreateTeam("")
+	}
+	teams := g.sortedTeams()[:n]
+
+	members := make([][]int, n)
+	for i, playerID := range g.TurnOrder {
+		k := i % n
+		g.Players[playerID].TeamID = teams[k].ID
+		members[k] = append(members[k], playerID)
+	}
+
+	order := make([]int, 0, len(g.TurnOrder))
+	for i := 0; len(order) < len(g.TurnOrder); i++ {
+		for _, m := range members {
+			if i < len(m) {
+				order = append(order, m[i])
+			}
+		}
+	}
+	g.SetTurnOrder(order)
+}
+
+func (g Game) playerTeam(playerID int) *Team {
+	player, ok := g.Players[playerID]
+	if !ok {
+		return nil
+	}
+	return g.Teams[player.TeamID]
+}
+
+func (g Game) sortedTeams() []*Team {
+	teams := make([]*Team, 0, len(g.Teams))
+	for _, team := range g.Teams {
+		teams = append(teams, team)
+	}
+	sort.Slice(teams, func(i, j int) bool { return teams[i].ID < teams[j].ID })
+	return teams
+}
+
 // Reset resets a game
 // modes
 // 0: delete all cards from piles then go back to waiting phase
-// 1: reset piles
+// 1: reset piles, a finished game is played again from the first round
 func (g *Game) Reset(mode int) {
+	g.TurnDeadline = time.Time{}
 	switch mode {
 	case 0:
 		g.DrawPile.Reset()
 		g.DiscardPile.Reset()
 		g.Phase = WaitingPhase
+		g.Turn = 0
+		g.Draws = nil
+		g.DuplicateFlags = nil
+		g.unflagCards()
 		for _, player := range g.Players {
 			player.NumberOfSubmittedCards = 0
+			player.Hand = nil
 		}
+		g.resetScores()
 	case 1:
-		g.DrawPile.Cards = append(g.DrawPile.Cards, g.DiscardPile.Cards...)
-		g.DiscardPile.Reset()
-		g.DrawPile.Shuffle()
+		if g.Phase == FinishedPhase {
+			g.Phase = PlayPhase
+			g.Draws = nil
+			g.resetScores()
+		}
+		g.recyclePiles()
 	}
 }
 
 // Start changes game phase to submit phase
 func (g *Game) Start() {
 	g.Phase = SubmitPhase
+	g.Turn = 0
 }
 
 // AddCard adds a card to the game
@@ -160,50 +693,141 @@ func (g *Game) AddCard(text string, playerID int) *Card {
 		return nil
 	}
 
-	card := &Card{
-		Text:   text,
-		Author: player.Name,
-	}
+	card := NewCard(0, text, player.Name, player.ID)
 	g.DrawPile.Push(card)
 	player.NumberOfSubmittedCards++
+	g.playIfAllSubmitted()
+
+	return card
+}
 
-	allSubmitted := true
+// playIfAllSubmitted shuffles the draw pile and starts playing once every connected player has submitted their cards
+// a room without any connected player keeps waiting
+func (g *Game) playIfAllSubmitted() {
+	connected := 0
 	for _, player := range g.Players {
+		if !player.Connected {
+			continue
+		}
 		if player.NumberOfSubmittedCards < g.CardsPerPlayer {
-			allSubmitted = false
-			break
+			return
 		}
+		connected++
+	}
+	if connected == 0 {
+		return
 	}
+	g.shuffleDrawPile()
+	g.Phase = PlayPhase
+}
 
-	if allSubmitted {
-		g.DrawPile.Shuffle()
-		g.Phase = PlayPhase
+// EditCard changes a text of a submitted card
+func (g *Game) EditCard(cardID int, text string) *Card {
+	card := g.DrawPile.Find(cardID)
+	if card == nil {
+		return nil
 	}
+	card.Text = text
+	g.unflagCard(cardID)
+	g.unflagContent(cardID)
+	return card
+}
 
+// DeleteCard deletes a submitted card
+func (g *Game) DeleteCard(cardID int) *Card {
+	card := g.DrawPile.Remove(cardID)
+	if card == nil {
+		return nil
+	}
+	g.unflagCard(cardID)
+	g.unflagContent(cardID)
+	if player, ok := g.Players[card.AuthorID]; ok && player.NumberOfSubmittedCards > 0 {
+		player.NumberOfSubmittedCards--
+	}
 	return card
 }
 
+// submittedCards returns cards in every pile and hand written by the player
+func (g Game) submittedCards(playerID int) []*Card {
+	cards := make([]*Card, 0)
+	piles := [][]*Card{g.DrawPile.Cards, g.DiscardPile.Cards}
+	for _, player := range g.Players {
+		piles = append(piles, player.Hand)
+	}
+	for _, pile := range piles {
+		for _, card := range pile {
+			if card.AuthorID == playerID {
+				cards = append(cards, card)
+			}
+		}
+	}
+	sort.Slice(cards, func(i, j int) bool { return cards[i].ID < cards[j].ID })
+	return cards
+}
+
 // State returns a game state for player with given player id
 func (g Game) State(playerID int) State {
-	players := make([]*Player, 0, len(g.Players))
-	for i := 1; i <= len(g.Players); i++ {
-		player, ok := g.Players[i]
-		if ok {
-			players = append(players, player)
-		}
+	var summary *Summary
+	if g.Phase == FinishedPhase {
+		summary = g.Summary()
+	}
+	players := make([]*PlayerState, 0, len(g.Players))
+	for _, player := range g.Players {
+		players = append(players, player.State())
+	}
+	sort.Slice(players, func(i, j int) bool { return players[i].ID < players[j].ID })
+	hand := make([]*Card, 0)
+	token := ""
+	if player, ok := g.Players[playerID]; ok {
+		hand = append(hand, player.Hand...)
+		token = player.Token
+	}
+	var duplicateFlags []*DuplicateFlag
+	var contentFlags []*ContentFlag
+	if playerID != SystemPlayerID && g.isHost(playerID) {
+		duplicateFlags = g.DuplicateFlags
+		contentFlags = g.ContentFlags
 	}
 	return State{
-		Phase:            g.Phase.String(),
-		DrawPileLeft:     g.DrawPile.Len(),
-		DiscardCards:     g.DiscardPile.Cards,
-		CardsPerPlayer:   g.CardsPerPlayer,
-		PlayerID:         playerID,
-		HostID:           g.HostID,
-		Players:          players,
-		LastDrawPlayerID: g.LastDrawPlayerID,
+		Phase:                g.Phase.String(),
+		DrawPileLeft:         g.DrawPile.Len(),
+		DiscardCards:         g.DiscardPile.Cards,
+		CardsPerPlayer:       g.CardsPerPlayer,
+		PlayerID:             playerID,
+		HostID:               g.HostID,
+		Players:              players,
+		Hand:                 hand,
+		SessionToken:         token,
+		MyCards:              g.submittedCards(playerID),
+		LastDrawPlayerID:     g.LastDrawPlayerID,
+		TurnOrder:            g.TurnOrder,
+		CurrentPlayerID:      g.CurrentPlayerID(),
+		NextPlayerID:         g.NextPlayerID(),
+		Teams:                g.sortedTeams(),
+		Rounds:               g.Rounds,
+		Round:                g.Round,
+		Summary:              summary,
+		TurnDuration:         int(g.TurnDuration / time.Second),
+		TurnTimeLeft:         int(math.Ceil(g.TurnTimeLeft().Seconds())),
+		CanUndo:              g.CanUndo(),
+		DuplicatePolicy:      g.DuplicatePolicy,
+		DuplicateFlags:       duplicateFlags,
+		ContentFilterEnabled: g.ContentFilterEnabled,
+		ContentFlags:         contentFlags,
 	}
 }
 
+// SpectatorState returns a game state for a spectator, no private card is included
+func (g Game) SpectatorState(spectatorID int) State {
+	state := g.State(SystemPlayerID)
+	state.PlayerID = spectatorID
+	state.Spectator = true
+	state.Hand = make([]*Card, 0)
+	state.MyCards = make([]*Card, 0)
+	state.SessionToken = ""
+	return state
+}
+
 // Command represents a game command
 type Command struct {
 	Name     string
@@ -228,19 +852,157 @@ type (
 		ID int `json:"id"`
 	}
 
+	// PlayerPayload is a connect or disconnect player payload
+	PlayerPayload struct {
+		ID int `json:"id"`
+	}
+
 	// AddCardPayload is an add card payload
 	AddCardPayload struct {
 		Text string `json:"text"`
 	}
 
+	// EditCardPayload is an edit card payload
+	EditCardPayload struct {
+		CardID int    `json:"card_id"`
+		Text   string `json:"text"`
+	}
+
+	// DeleteCardPayload is a delete card payload
+	DeleteCardPayload struct {
+		CardID int `json:"card_id"`
+	}
+
+	// SetCardLengthPayload is a set card length payload
+	SetCardLengthPayload struct {
+		MinLength int `json:"min_length"`
+		MaxLength int `json:"max_length"`
+	}
+
+	// SetDuplicatePolicyPayload is a set duplicate policy payload
+	SetDuplicatePolicyPayload struct {
+		Policy string `json:"policy"`
+	}
+
+	// SetContentFilterPayload is a set content filter payload
+	SetContentFilterPayload struct {
+		Enabled bool `json:"enabled"`
+	}
+
+	// ImportCardsPayload is an import cards payload, data is written in the format
+	ImportCardsPayload struct {
+		Format     string `json:"format"`
+		Data       string `json:"data"`
+		SkipSubmit bool   `json:"skip_submit"`
+	}
+
+	// ExportPayload is an export payload
+	ExportPayload struct {
+		Format string `json:"format"`
+	}
+
+	// KickPlayerPayload is a kick player payload
+	KickPlayerPayload struct {
+		PlayerID int `json:"player_id"`
+	}
+
+	// BanPlayerPayload is a ban player payload
+	BanPlayerPayload struct {
+		PlayerID int `json:"player_id"`
+	}
+
+	// TransferHostPayload is a transfer host payload
+	TransferHostPayload struct {
+		PlayerID int `json:"player_id"`
+	}
+
 	// ResetPayload is a reset payload
 	ResetPayload struct {
 		Mode int `json:"mode"`
 	}
+
+	// SetTurnOrderPayload is a set turn order payload
+	SetTurnOrderPayload struct {
+		PlayerIDs []int `json:"player_ids"`
+	}
+
+	// CreateTeamPayload is a create team payload
+	CreateTeamPayload struct {
+		Name string `json:"name"`
+	}
+
+	// RenameTeamPayload is a rename team payload
+	RenameTeamPayload struct {
+		TeamID int    `json:"team_id"`
+		Name   string `json:"name"`
+	}
+
+	// AssignTeamPayload is an assign team payload
+	AssignTeamPayload struct {
+		PlayerID int `json:"player_id"`
+		TeamID   int `json:"team_id"`
+	}
+
+	// BalanceTeamsPayload is a balance teams payload
+	BalanceTeamsPayload struct {
+		NumberOfTeams int `json:"number_of_teams"`
+	}
+
+	// SetTurnDurationPayload is a set turn duration payload
+	SetTurnDurationPayload struct {
+		Seconds int `json:"seconds"`
+	}
+
+	// SetRoundsPayload is a set rounds payload
+	SetRoundsPayload struct {
+		Rounds []*Round `json:"rounds"`
+	}
 )
 
-// ExecCommand executes a command
+// ExecCommand executes a command, an accepted command is appended to the event log
 func (g *Game) ExecCommand(cmd Command) error {
+	if IsSystemCommand(cmd.Name) && cmd.PlayerID != SystemPlayerID {
+		return InvalidCommandErr{cmd: cmd}
+	}
+	if cmd.Name == "undo" {
+		if err := g.undo(cmd); err != nil {
+			return err
+		}
+		g.appendEvent(cmd)
+		return nil
+	}
+
+	var snapshot []byte
+	if undoableCommands[cmd.Name] {
+		var err error
+		if snapshot, err = g.snapshot(); err != nil {
+			return err
+		}
+	}
+	if err := g.execCommand(cmd); err != nil {
+		return err
+	}
+	// restoring an older board would silently revert any other command, so it ends the undo history
+	if snapshot != nil {
+		g.pushHistory(snapshot)
+	} else if !readOnlyCommands[cmd.Name] {
+		g.history = nil
+	}
+	g.appendEvent(cmd)
+	return nil
+}
+
+func (g *Game) undo(cmd Command) error {
+	if !g.isHost(cmd.PlayerID) {
+		return CommandIsForHostOnlyErr{cmd: cmd}
+	}
+	if !g.CanUndo() {
+		return NothingToUndoErr{cmd: cmd}
+	}
+	return g.Undo()
+}
+
+func (g *Game) execCommand(cmd Command) error {
 	switch cmd.Name {
 	case "set_cards_per_player":
 		if !g.isHost(cmd.PlayerID) {
@@ -256,26 +1018,341 @@ func (g *Game) ExecCommand(cmd Command) error {
 		if !ok {
 			return InvalidCommandErr{cmd: cmd}
 		}
-		g.AddPlayer(payload.ID, payload.Name)
+		name, action := g.FilterText(payload.Name)
+		if action == RejectContent {
+			return ContentRejectedErr{cmd: cmd}
+		}
+		// the log keeps the name as filtered, a masked word must not come back through it
+		payload.Name = name
+		g.AddPlayer(payload.ID, name)
+		if action == FlagContent {
+			g.flagContent(payload.ID, 0, name)
+		}
 	case "remove_player":
 		payload, ok := cmd.Payload.(*RemovePlayerPayload)
 		if !ok {
 			return InvalidCommandErr{cmd: cmd}
 		}
 		g.RemovePlayer(payload.ID)
+	case "connect_player":
+		payload, ok := cmd.Payload.(*PlayerPayload)
+		if !ok || g.Players[payload.ID] == nil {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		g.ReconnectPlayer(payload.ID)
+	case "disconnect_player":
+		payload, ok := cmd.Payload.(*PlayerPayload)
+		if !ok || g.Players[payload.ID] == nil {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		g.DisconnectPlayer(payload.ID)
+	case "expire_turn":
+		if g.Phase != PlayPhase {
+			return InvalidPhaseErr{cmd: cmd, phase: g.Phase}
+		}
+		g.ExpireTurn()
+	case "kick_player":
+		if !g.isHost(cmd.PlayerID) {
+			return CommandIsForHostOnlyErr{cmd: cmd}
+		}
+		payload, ok := cmd.Payload.(*KickPlayerPayload)
+		if !ok || g.Players[payload.PlayerID] == nil || payload.PlayerID == cmd.PlayerID {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		g.KickPlayer(payload.PlayerID)
+	case "ban_player":
+		if !g.isHost(cmd.PlayerID) {
+			return CommandIsForHostOnlyErr{cmd: cmd}
+		}
+		payload, ok := cmd.Payload.(*BanPlayerPayload)
+		if !ok || g.Players[payload.PlayerID] == nil || payload.PlayerID == cmd.PlayerID {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		g.BanPlayer(payload.PlayerID)
+	case "transfer_host":
+		if !g.isHost(cmd.PlayerID) {
+			return CommandIsForHostOnlyErr{cmd: cmd}
+		}
+		payload, ok := cmd.Payload.(*TransferHostPayload)
+		if !ok || !g.isConnected(payload.PlayerID) {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		g.PromoteHost(payload.PlayerID)
 	case "start":
 		if !g.isHost(cmd.PlayerID) {
 			return CommandIsForHostOnlyErr{cmd: cmd}
 		}
+		if g.Phase != WaitingPhase {
+			return InvalidPhaseErr{cmd: cmd, phase: g.Phase}
+		}
 		g.Start()
 	case "draw_card":
+		if g.Phase != PlayPhase {
+			return InvalidPhaseErr{cmd: cmd, phase: g.Phase}
+		}
+		if g.CurrentPlayerID() != cmd.PlayerID {
+			return NotPlayerTurnErr{cmd: cmd, currentPlayerID: g.CurrentPlayerID()}
+		}
+		if g.hasCardInHand(cmd.PlayerID) {
+			return CardNotResolvedErr{cmd: cmd}
+		}
+		if g.DrawPile.Len() == 0 {
+			return EmptyDrawPileErr{cmd: cmd}
+		}
 		g.DrawCard(cmd.PlayerID)
+	case "guess_card", "skip_card", "pass_card":
+		if g.Phase != PlayPhase {
+			return InvalidPhaseErr{cmd: cmd, phase: g.Phase}
+		}
+		if g.CurrentPlayerID() != cmd.PlayerID {
+			return NotPlayerTurnErr{cmd: cmd, currentPlayerID: g.CurrentPlayerID()}
+		}
+		if !g.hasCardInHand(cmd.PlayerID) {
+			return EmptyHandErr{cmd: cmd}
+		}
+		switch cmd.Name {
+		case "guess_card":
+			g.GuessCard(cmd.PlayerID)
+		case "skip_card":
+			g.SkipCard(cmd.PlayerID)
+		case "pass_card":
+			g.PassCard(cmd.PlayerID)
+		}
+	case "create_team":
+		if !g.isHost(cmd.PlayerID) {
+			return CommandIsForHostOnlyErr{cmd: cmd}
+		}
+		payload, ok := cmd.Payload.(*CreateTeamPayload)
+		if !ok {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		g.CreateTeam(payload.Name)
+	case "rename_team":
+		if !g.isHost(cmd.PlayerID) {
+			return CommandIsForHostOnlyErr{cmd: cmd}
+		}
+		payload, ok := cmd.Payload.(*RenameTeamPayload)
+		if !ok || g.Teams[payload.TeamID] == nil || payload.Name == "" {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		g.RenameTeam(payload.TeamID, payload.Name)
+	case "assign_team":
+		payload, ok := cmd.Payload.(*AssignTeamPayload)
+		if !ok || g.Players[payload.PlayerID] == nil || (payload.TeamID != 0 && g.Teams[payload.TeamID] == nil) {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		if payload.PlayerID != cmd.PlayerID && !g.isHost(cmd.PlayerID) {
+			return CommandIsForHostOnlyErr{cmd: cmd}
+		}
+		g.AssignTeam(payload.PlayerID, payload.TeamID)
+	case "balance_teams":
+		if !g.isHost(cmd.PlayerID) {
+			return CommandIsForHostOnlyErr{cmd: cmd}
+		}
+		payload, ok := cmd.Payload.(*BalanceTeamsPayload)
+		if !ok || payload.NumberOfTeams < 0 || payload.NumberOfTeams > len(g.Players) {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		g.BalanceTeams(payload.NumberOfTeams)
+	case "set_rounds":
+		if !g.isHost(cmd.PlayerID) {
+			return CommandIsForHostOnlyErr{cmd: cmd}
+		}
+		if g.Phase != WaitingPhase && g.Phase != SubmitPhase {
+			return InvalidPhaseErr{cmd: cmd, phase: g.Phase}
+		}
+		payload, ok := cmd.Payload.(*SetRoundsPayload)
+		if !ok || !isValidRounds(payload.Rounds) {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		g.SetRounds(payload.Rounds)
+	case "set_turn_duration":
+		if !g.isHost(cmd.PlayerID) {
+			return CommandIsForHostOnlyErr{cmd: cmd}
+		}
+		payload, ok := cmd.Payload.(*SetTurnDurationPayload)
+		if !ok || payload.Seconds < 0 || time.Duration(payload.Seconds)*time.Second > maxTurnDuration {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		g.SetTurnDuration(time.Duration(payload.Seconds) * time.Second)
+	case "end_turn":
+		if g.Phase != PlayPhase {
+			return InvalidPhaseErr{cmd: cmd, phase: g.Phase}
+		}
+		if g.CurrentPlayerID() != cmd.PlayerID && !g.isHost(cmd.PlayerID) {
+			return NotPlayerTurnErr{cmd: cmd, currentPlayerID: g.CurrentPlayerID()}
+		}
+		g.returnHand(g.CurrentPlayerID())
+		g.NextTurn()
+	case "set_turn_order":
+		if !g.isHost(cmd.PlayerID) {
+			return CommandIsForHostOnlyErr{cmd: cmd}
+		}
+		payload, ok := cmd.Payload.(*SetTurnOrderPayload)
+		if !ok || !g.isValidTurnOrder(payload.PlayerIDs) {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		g.SetTurnOrder(payload.PlayerIDs)
+	case "set_card_length":
+		if !g.isHost(cmd.PlayerID) {
+			return CommandIsForHostOnlyErr{cmd: cmd}
+		}
+		if g.Phase != WaitingPhase && g.Phase != SubmitPhase {
+			return InvalidPhaseErr{cmd: cmd, phase: g.Phase}
+		}
+		payload, ok := cmd.Payload.(*SetCardLengthPayload)
+		if !ok {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		validator := CardValidator{MinLength: payload.MinLength, MaxLength: payload.MaxLength}
+		if !validator.isValid() {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		g.CardValidator = validator
 	case "add_card":
+		if g.Phase != SubmitPhase {
+			return InvalidPhaseErr{cmd: cmd, phase: g.Phase}
+		}
 		payload, ok := cmd.Payload.(*AddCardPayload)
 		if !ok {
 			return InvalidCommandErr{cmd: cmd}
 		}
-		g.AddCard(payload.Text, cmd.PlayerID)
+		text, err := g.CardValidator.Normalize(payload.Text)
+		if err != nil {
+			return InvalidCardTextErr{cmd: cmd, err: err, validator: g.CardValidator}
+		}
+		text, action := g.FilterText(text)
+		if action == RejectContent {
+			return ContentRejectedErr{cmd: cmd}
+		}
+		payload.Text = text
+		var card *Card
+		duplicate, exact := g.findDuplicate(text, 0)
+		if duplicate == nil {
+			card = g.AddCard(text, cmd.PlayerID)
+		} else {
+			switch g.DuplicatePolicy {
+			case MergeDuplicates:
+				g.MergeCard(cmd.PlayerID)
+			case FlagDuplicates:
+				if card = g.AddCard(text, cmd.PlayerID); card != nil {
+					g.flagDuplicate(card, duplicate, exact)
+				}
+			default:
+				return DuplicateCardErr{cmd: cmd, exact: exact}
+			}
+		}
+		if card != nil && action == FlagContent {
+			g.flagContent(cmd.PlayerID, card.ID, card.Text)
+		}
+	case "set_content_filter":
+		if !g.isHost(cmd.PlayerID) {
+			return CommandIsForHostOnlyErr{cmd: cmd}
+		}
+		payload, ok := cmd.Payload.(*SetContentFilterPayload)
+		if !ok {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		g.ContentFilterEnabled = payload.Enabled
+	case "set_duplicate_policy":
+		if !g.isHost(cmd.PlayerID) {
+			return CommandIsForHostOnlyErr{cmd: cmd}
+		}
+		payload, ok := cmd.Payload.(*SetDuplicatePolicyPayload)
+		if !ok || !isValidDuplicatePolicy(payload.Policy) {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		g.DuplicatePolicy = payload.Policy
+	case "import_cards":
+		if cmd.PlayerID != SystemPlayerID && !g.isHost(cmd.PlayerID) {
+			return CommandIsForHostOnlyErr{cmd: cmd}
+		}
+		if g.Phase != WaitingPhase && g.Phase != SubmitPhase {
+			return InvalidPhaseErr{cmd: cmd, phase: g.Phase}
+		}
+		payload, ok := cmd.Payload.(*ImportCardsPayload)
+		if !ok {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		texts, err := ParseCards(payload.Format, []byte(payload.Data))
+		if err != nil || len(texts) > maxImportedCards {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		imported := g.ImportCards(texts, payload.SkipSubmit)
+		// nothing has changed if nothing has been imported, so the command can still be rejected
+		if payload.SkipSubmit && g.DrawPile.Len() == 0 {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		// the log keeps only the imported texts as filtered, not the uploaded data
+		data, err := json.Marshal(imported)
+		if err != nil {
+			return err
+		}
+		payload.Format, payload.Data = JSONFormat, string(data)
+	case "edit_card":
+		payload, ok := cmd.Payload.(*EditCardPayload)
+		if !ok {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		if err := g.checkCardAuthor(cmd, payload.CardID); err != nil {
+			return err
+		}
+		text, err := g.CardValidator.Normalize(payload.Text)
+		if err != nil {
+			return InvalidCardTextErr{cmd: cmd, err: err, validator: g.CardValidator}
+		}
+		text, action := g.FilterText(text)
+		if action == RejectContent {
+			return ContentRejectedErr{cmd: cmd}
+		}
+		payload.Text = text
+		var card *Card
+		duplicate, exact := g.findDuplicate(text, payload.CardID)
+		if duplicate == nil {
+			card = g.EditCard(payload.CardID, text)
+		} else {
+			switch g.DuplicatePolicy {
+			case MergeDuplicates:
+				g.mergeEditedCard(payload.CardID)
+			case FlagDuplicates:
+				if card = g.EditCard(payload.CardID, text); card != nil {
+					g.flagDuplicate(card, duplicate, exact)
+				}
+			default:
+				return DuplicateCardErr{cmd: cmd, exact: exact}
+			}
+		}
+		if card != nil && action == FlagContent {
+			g.flagContent(cmd.PlayerID, card.ID, card.Text)
+		}
+	case "delete_card":
+		payload, ok := cmd.Payload.(*DeleteCardPayload)
+		if !ok {
+			return InvalidCommandErr{cmd: cmd}
+		}
+		if err := g.checkCardAuthor(cmd, payload.CardID); err != nil {
+			return err
+		}
+		g.DeleteCard(payload.CardID)
+	case "end_game":
+		if !g.isHost(cmd.PlayerID) {
+			return CommandIsForHostOnlyErr{cmd: cmd}
+		}
+		if g.Phase != SubmitPhase && g.Phase != PlayPhase {
+			return InvalidPhaseErr{cmd: cmd, phase: g.Phase}
+		}
+		g.EndGame()
+	case "export":
+		if !g.isHost(cmd.PlayerID) {
+			return CommandIsForHostOnlyErr{cmd: cmd}
+		}
+		if g.Phase != FinishedPhase {
+			return InvalidPhaseErr{cmd: cmd, phase: g.Phase}
+		}
+		payload, ok := cmd.Payload.(*ExportPayload)
+		if !ok || (payload.Format != JSONFormat && payload.Format != CSVFormat) {
+			return InvalidCommandErr{cmd: cmd}
+		}
 	case "reset":
 		if !g.isHost(cmd.PlayerID) {
 			return CommandIsForHostOnlyErr{cmd: cmd}
@@ -285,11 +1362,28 @@ func (g *Game) ExecCommand(cmd Command) error {
 			return InvalidCommandErr{cmd: cmd}
 		}
 		g.Reset(payload.Mode)
+	default:
+		return UnknownCommandErr{cmd: cmd}
 	}
 
 	return nil
 }
 
+// checkCardAuthor checks if the command can change the submitted card
+func (g Game) checkCardAuthor(cmd Command, cardID int) error {
+	if g.Phase != SubmitPhase {
+		return InvalidPhaseErr{cmd: cmd, phase: g.Phase}
+	}
+	card := g.DrawPile.Find(cardID)
+	if card == nil {
+		return CardNotFoundErr{cmd: cmd, cardID: cardID}
+	}
+	if card.AuthorID != cmd.PlayerID {
+		return NotCardAuthorErr{cmd: cmd, cardID: cardID}
+	}
+	return nil
+}
+
 // InvalidCommandErr occurs when a game command is invalid
 type InvalidCommandErr struct {
 	cmd Command
@@ -299,6 +1393,10 @@ func (e InvalidCommandErr) Error() string {
 	return fmt.Sprintf("cmd: %s with payload: %v is invalid", e.cmd.Name, e.cmd.Payload)
 }
 
+func (e InvalidCommandErr) Code() string {
+	return "invalid_command"
+}
+
 // CommandIsForHostOnlyErr occurs when a non host player try to execute host only command
 type CommandIsForHostOnlyErr struct {
 	cmd Command
@@ -307,3 +1405,138 @@ type CommandIsForHostOnlyErr struct {
 func (e CommandIsForHostOnlyErr) Error() string {
 	return fmt.Sprintf("player %d is not a host, cmd: %s must be executed by host player", e.cmd.PlayerID, e.cmd.Name)
 }
+
+func (e CommandIsForHostOnlyErr) Code() string {
+	return "host_only"
+}
+
+// NotPlayerTurnErr occurs when a player try to execute a command out of their turn
+type NotPlayerTurnErr struct {
+	cmd             Command
+	currentPlayerID int
+}
+
+func (e NotPlayerTurnErr) Error() string {
+	return fmt.Sprintf("it is not player %d's turn, cmd: %s must be executed by player %d", e.cmd.PlayerID, e.cmd.Name, e.currentPlayerID)
+}
+
+func (e NotPlayerTurnErr) Code() string {
+	return "not_player_turn"
+}
+
+// InvalidPhaseErr occurs when a command is not allowed in the current game phase
+type InvalidPhaseErr struct {
+	cmd   Command
+	phase Phase
+}
+
+func (e InvalidPhaseErr) Error() string {
+	return fmt.Sprintf("cmd: %s is not allowed in %s", e.cmd.Name, e.phase)
+}
+
+func (e InvalidPhaseErr) Code() string {
+	return "invalid_phase"
+}
+
+// CardNotResolvedErr occurs when a player try to draw while holding a card
+type CardNotResolvedErr struct {
+	cmd Command
+}
+
+func (e CardNotResolvedErr) Error() string {
+	return fmt.Sprintf("player %d must guess, skip or pass the card in hand before cmd: %s", e.cmd.PlayerID, e.cmd.Name)
+}
+
+func (e CardNotResolvedErr) Code() string {
+	return "card_not_resolved"
+}
+
+// EmptyHandErr occurs when a player try to play a card without holding one
+type EmptyHandErr struct {
+	cmd Command
+}
+
+func (e EmptyHandErr) Error() string {
+	return fmt.Sprintf("player %d has no card in hand, cmd: %s needs a drawn card", e.cmd.PlayerID, e.cmd.Name)
+}
+
+func (e EmptyHandErr) Code() string {
+	return "empty_hand"
+}
+
+// EmptyDrawPileErr occurs when a player try to draw a card from an empty draw pile
+type EmptyDrawPileErr struct {
+	cmd Command
+}
+
+func (e EmptyDrawPileErr) Error() string {
+	return fmt.Sprintf("the draw pile is empty, cmd: %s", e.cmd.Name)
+}
+
+func (e EmptyDrawPileErr) Code() string {
+	return "empty_draw_pile"
+}
+
+// CardNotFoundErr occurs when a command refers to a card that does not exist
+type CardNotFoundErr struct {
+	cmd    Command
+	cardID int
+}
+
+func (e CardNotFoundErr) Error() string {
+	return fmt.Sprintf("card %d is not found, cmd: %s", e.cardID, e.cmd.Name)
+}
+
+func (e CardNotFoundErr) Code() string {
+	return "card_not_found"
+}
+
+// NotCardAuthorErr occurs when a player try to change a card written by another player
+type NotCardAuthorErr struct {
+	cmd    Command
+	cardID int
+}
+
+func (e NotCardAuthorErr) Error() string {
+	return fmt.Sprintf("player %d is not the author of card %d, cmd: %s must be executed by the author", e.cmd.PlayerID, e.cardID, e.cmd.Name)
+}
+
+func (e NotCardAuthorErr) Code() string {
+	return "not_card_author"
+}
+
+// NothingToUndoErr occurs when the host undoes with an empty undo history
+type NothingToUndoErr struct {
+	cmd Command
+}
+
+func (e NothingToUndoErr) Error() string {
+	return fmt.Sprintf("cmd: %s has nothing to undo", e.cmd.Name)
+}
+
+func (e NothingToUndoErr) Code() string {
+	return "nothing_to_undo"
+}
+
+// UnknownCommandErr occurs when a command name does not exist
+type UnknownCommandErr struct {
+	cmd Command
+}
+
+func (e UnknownCommandErr) Error() string {
+	return fmt.Sprintf("invalid game command: %s", e.cmd.Name)
+}
+
+func (e UnknownCommandErr) Code() string {
+	return "unknown_command"
+}
+
+// ReplayErr occurs when an event of the log cannot be replayed
+type ReplayErr struct {
+	event *Event
+	err   error
+}
+
+func (e ReplayErr) Error() string {
+	return fmt.Sprintf("failed to replay event %d: %s: %v", e.event.Seq, e.event.Name, e.err)
+}
@@ -0,0 +1,481 @@
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+	"whatthecard/pkg/logger"
+)
+
+// fakeClock is a Clock whose time only moves when the test advances it
+// scheduled functions run synchronously once the time passes their deadline
+type fakeClock struct {
+	now    time.Time
+	timers []*fakeTimer
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	timer := &fakeTimer{deadline: c.now.Add(d), f: f}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+// Advance moves the time forward and runs every function whose deadline has passed
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+	timers := c.timers
+	c.timers = nil
+	for _, timer := range timers {
+		if timer.stopped {
+			continue
+		}
+		if timer.deadline.After(c.now) {
+			c.timers = append(c.timers, timer)
+			continue
+		}
+		timer.stopped = true
+		timer.f()
+	}
+}
+
+type fakeTimer struct {
+	deadline time.Time
+	f        func()
+	stopped  bool
+}
+
+func (t *fakeTimer) Stop() bool {
+	active := !t.stopped
+	t.stopped = true
+	return active
+}
+
+func newTestService(clock Clock) *Service {
+	return NewService(clock, rand.NewSource(1), nil, logger.NewLogger(""))
+}
+
+// exec executes the command and fails the test if it is rejected
+func exec(t *testing.T, g *Game, name string, playerID int, payload interface{}) {
+	t.Helper()
+	if err := g.ExecCommand(Command{Name: name, PlayerID: playerID, Payload: payload}); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+}
+
+// newTestGame returns a game with the players in the submit phase, player 1 is the host
+func newTestGame(t *testing.T, s *Service, players ...string) *Game {
+	t.Helper()
+	g := s.NewGame()
+	for i, name := range players {
+		exec(t, g, "add_player", SystemPlayerID, &AddPlayerPayload{ID: i + 1, Name: name})
+	}
+	exec(t, g, "start", 1, nil)
+	return g
+}
+
+func TestSpectatorStateHidesDeckCards(t *testing.T) {
+	g := newTestGame(t, newTestService(NewRealClock()), "a", "b")
+	exec(t, g, "import_cards", 1, &ImportCardsPayload{Format: TextFormat, Data: "alpha\nbravo"})
+
+	state := g.SpectatorState(99)
+	if len(state.MyCards) != 0 || len(state.Hand) != 0 || state.SessionToken != "" {
+		t.Errorf("spectator state has private data: my cards %v, hand %v, token %q", state.MyCards, state.Hand, state.SessionToken)
+	}
+	for _, id := range []int{SystemPlayerID, 1, 2} {
+		if cards := g.State(id).MyCards; len(cards) != 0 {
+			t.Errorf("player %d sees deck cards as their own: %v", id, cards)
+		}
+	}
+}
+
+func TestFinishedGameCannotBeReopened(t *testing.T) {
+	g := newTestGame(t, newTestService(NewRealClock()), "a", "b")
+	exec(t, g, "end_game", 1, nil)
+
+	for _, cmd := range []Command{
+		{Name: "add_card", PlayerID: 2, Payload: &AddCardPayload{Text: "alpha"}},
+		{Name: "start", PlayerID: 1},
+	} {
+		if _, ok := g.ExecCommand(cmd).(InvalidPhaseErr); !ok {
+			t.Errorf("%s: want InvalidPhaseErr in the finished phase", cmd.Name)
+		}
+		if g.Phase != FinishedPhase {
+			t.Fatalf("%s: phase is %s, want %s", cmd.Name, g.Phase, FinishedPhase)
+		}
+	}
+}
+
+// playRound draws and guesses every card left in the current round
+func playRound(t *testing.T, g *Game) {
+	t.Helper()
+	round := g.Round
+	for g.Phase == PlayPhase && g.Round == round {
+		playerID := g.CurrentPlayerID()
+		exec(t, g, "draw_card", playerID, nil)
+		exec(t, g, "guess_card", playerID, nil)
+	}
+}
+
+func cardIDs(cards []*Card) []int {
+	ids := make([]int, 0, len(cards))
+	for _, card := range cards {
+		ids = append(ids, card.ID)
+	}
+	return ids
+}
+
+func TestRestoredGameShufflesLikeReplay(t *testing.T) {
+	s := newTestService(NewRealClock())
+	g := newTestGame(t, s, "a", "b")
+	exec(t, g, "set_cards_per_player", 1, &SetCardPerPlayerPayload{CardsPerPlayer: 5})
+	for _, text := range []string{"alpha", "bravo", "charlie", "delta", "echo"} {
+		exec(t, g, "add_card", 1, &AddCardPayload{Text: text})
+		exec(t, g, "add_card", 2, &AddCardPayload{Text: text + " " + text})
+	}
+	playRound(t, g)
+
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := append([]*Event{}, g.Events...)
+	restored, err := s.RestoreGame(data, append([]*Event{}, g.Events...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec(t, restored, "connect_player", SystemPlayerID, &PlayerPayload{ID: 1})
+	exec(t, restored, "connect_player", SystemPlayerID, &PlayerPayload{ID: 2})
+	playRound(t, restored)
+	events = append(events, restored.Events[len(g.Events):]...)
+
+	replayed, err := s.Replay(g.RoomID, g.Seed, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(cardIDs(replayed.DrawPile.Cards)), fmt.Sprint(cardIDs(restored.DrawPile.Cards)); got != want {
+		t.Errorf("replayed draw pile is %s, want %s", got, want)
+	}
+	if replayed.Round != 2 || restored.Round != 2 {
+		t.Errorf("got rounds %d and %d, want round 2", replayed.Round, restored.Round)
+	}
+}
+
+func TestReplayRebuildsGame(t *testing.T) {
+	s := newTestService(NewRealClock())
+	g := newTestGame(t, s, "a", "b", "c")
+	exec(t, g, "set_cards_per_player", 1, &SetCardPerPlayerPayload{CardsPerPlayer: 4})
+	for i, text := range []string{"alpha", "bravo", "charlie", "delta"} {
+		exec(t, g, "add_card", 1, &AddCardPayload{Text: text})
+		exec(t, g, "add_card", 2, &AddCardPayload{Text: text + " " + text})
+		exec(t, g, "add_card", 3, &AddCardPayload{Text: fmt.Sprintf("%s %d", text, i)})
+	}
+	playRound(t, g)
+
+	data, err := json.Marshal(g.Events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []*Event
+	if err := json.Unmarshal(data, &events); err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := s.Replay(g.RoomID, g.Seed, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkReplayedState(t, g, replayed)
+}
+
+// checkReplayedState fails the test if a player would see a different state in the replayed game
+func checkReplayedState(t *testing.T, g, replayed *Game) {
+	t.Helper()
+	for _, player := range g.Players {
+		want, got := g.State(player.ID), replayed.State(player.ID)
+		want.SessionToken, got.SessionToken = "", ""
+		wantJSON, err := json.Marshal(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotJSON, err := json.Marshal(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("replayed state for player %d is\n%s\nwant\n%s", player.ID, gotJSON, wantJSON)
+		}
+	}
+}
+
+func TestRestoredGameReplaysWhenTurnHolderStaysAway(t *testing.T) {
+	s := newTestService(NewRealClock())
+	g := newTestGame(t, s, "a", "b")
+	exec(t, g, "set_cards_per_player", 1, &SetCardPerPlayerPayload{CardsPerPlayer: 2})
+	for _, text := range []string{"alpha", "bravo"} {
+		exec(t, g, "add_card", 1, &AddCardPayload{Text: text})
+		exec(t, g, "add_card", 2, &AddCardPayload{Text: text + " " + text})
+	}
+	holderID := g.CurrentPlayerID()
+	exec(t, g, "draw_card", holderID, nil)
+
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := s.RestoreGame(data, append([]*Event{}, g.Events...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherID := 1
+	if holderID == 1 {
+		otherID = 2
+	}
+	exec(t, restored, "connect_player", SystemPlayerID, &PlayerPayload{ID: otherID})
+	exec(t, restored, "draw_card", otherID, nil)
+	if got := len(restored.Players[holderID].Hand); got != 0 {
+		t.Errorf("disconnected turn holder still holds %d cards", got)
+	}
+
+	replayed, err := s.Replay(g.RoomID, g.Seed, restored.Events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkReplayedState(t, restored, replayed)
+}
+
+func TestEditCardChecksDuplicates(t *testing.T) {
+	tests := []struct {
+		policy    string
+		wantErr   bool
+		wantCards int
+		wantFlags int
+	}{
+		{policy: RejectDuplicates, wantErr: true, wantCards: 2},
+		{policy: MergeDuplicates, wantCards: 1},
+		{policy: FlagDuplicates, wantCards: 2, wantFlags: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			g := newTestGame(t, newTestService(NewRealClock()), "a", "b")
+			exec(t, g, "set_cards_per_player", 1, &SetCardPerPlayerPayload{CardsPerPlayer: 3})
+			exec(t, g, "set_duplicate_policy", 1, &SetDuplicatePolicyPayload{Policy: tt.policy})
+			exec(t, g, "add_card", 1, &AddCardPayload{Text: "alpha"})
+			exec(t, g, "add_card", 2, &AddCardPayload{Text: "bravo"})
+			cardID := g.DrawPile.Cards[1].ID
+			exec(t, g, "edit_card", 2, &EditCardPayload{CardID: cardID, Text: "Bravo"})
+
+			err := g.ExecCommand(Command{Name: "edit_card", PlayerID: 2, Payload: &EditCardPayload{CardID: cardID, Text: "ALPHA"}})
+			if _, ok := err.(DuplicateCardErr); ok != tt.wantErr {
+				t.Fatalf("got error %v, want duplicate error %t", err, tt.wantErr)
+			}
+			if got := len(g.DrawPile.Cards); got != tt.wantCards {
+				t.Errorf("got %d cards, want %d", got, tt.wantCards)
+			}
+			if got := len(g.DuplicateFlags); got != tt.wantFlags {
+				t.Errorf("got %d duplicate flags, want %d", got, tt.wantFlags)
+			}
+			if got := g.Players[2].NumberOfSubmittedCards; got != 1 {
+				t.Errorf("player submitted %d cards, want 1", got)
+			}
+		})
+	}
+}
+
+func TestTurnExpiresAfterDeadline(t *testing.T) {
+	clock := newFakeClock()
+	g := newTestGame(t, newTestService(clock), "a", "b")
+	exec(t, g, "set_cards_per_player", 1, &SetCardPerPlayerPayload{CardsPerPlayer: 1})
+	exec(t, g, "set_turn_duration", 1, &SetTurnDurationPayload{Seconds: 30})
+	exec(t, g, "add_card", 1, &AddCardPayload{Text: "alpha"})
+	exec(t, g, "add_card", 2, &AddCardPayload{Text: "bravo"})
+
+	playerID := g.CurrentPlayerID()
+	exec(t, g, "draw_card", playerID, nil)
+	card := g.Players[playerID].Hand[0]
+	// the room schedules the expiry the same way once a command sets the deadline
+	clock.AfterFunc(g.TurnTimeLeft(), func() {
+		exec(t, g, "expire_turn", SystemPlayerID, nil)
+	})
+
+	clock.Advance(29 * time.Second)
+	if got := g.TurnTimeLeft(); got != time.Second {
+		t.Fatalf("got %v left, want 1s", got)
+	}
+	if g.CurrentPlayerID() != playerID || len(g.Players[playerID].Hand) != 1 {
+		t.Fatal("turn has expired before its deadline")
+	}
+
+	clock.Advance(2 * time.Second)
+	if got := len(g.Players[playerID].Hand); got != 0 {
+		t.Errorf("player still holds %d cards", got)
+	}
+	if g.DrawPile.Find(card.ID) == nil {
+		t.Errorf("card %d has not been returned to the draw pile", card.ID)
+	}
+	if g.CurrentPlayerID() == playerID {
+		t.Errorf("turn has not been passed on from player %d", playerID)
+	}
+	if !g.TurnDeadline.IsZero() || g.TurnTimeLeft() != 0 {
+		t.Errorf("got deadline %v, want no deadline until the next draw", g.TurnDeadline)
+	}
+}
+
+func TestStateDoesNotExposeSeed(t *testing.T) {
+	g := newTestGame(t, newTestService(NewRealClock()), "a", "b")
+	for _, state := range []State{g.State(1), g.SpectatorState(3)} {
+		data, err := json.Marshal(state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields := make(map[string]interface{})
+		if err := json.Unmarshal(data, &fields); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := fields["seed"]; ok {
+			t.Errorf("state of player %d exposes the shuffle seed", state.PlayerID)
+		}
+	}
+}
+
+func TestEndTurnReturnsDrawnCard(t *testing.T) {
+	g := newTestGame(t, newTestService(NewRealClock()), "a", "b")
+	exec(t, g, "set_cards_per_player", 1, &SetCardPerPlayerPayload{CardsPerPlayer: 1})
+	exec(t, g, "add_card", 1, &AddCardPayload{Text: "alpha"})
+	exec(t, g, "add_card", 2, &AddCardPayload{Text: "bravo"})
+
+	playerID := g.CurrentPlayerID()
+	exec(t, g, "draw_card", playerID, nil)
+	card := g.Players[playerID].Hand[0]
+	exec(t, g, "end_turn", playerID, nil)
+	if got := len(g.Players[playerID].Hand); got != 0 {
+		t.Errorf("player %d still holds %d cards after ending the turn", playerID, got)
+	}
+	if g.DrawPile.Find(card.ID) == nil {
+		t.Errorf("card %d has not been returned to the draw pile", card.ID)
+	}
+
+	nextID := g.CurrentPlayerID()
+	if nextID == playerID {
+		t.Fatalf("turn has not been passed on from player %d", playerID)
+	}
+	playRound(t, g)
+	if g.Round != 1 && g.Phase != FinishedPhase {
+		t.Errorf("got round %d in %s, want the next round", g.Round, g.Phase)
+	}
+}
+
+func TestDrawCardFromEmptyPileIsRejected(t *testing.T) {
+	g := newTestGame(t, newTestService(NewRealClock()), "a", "b")
+	exec(t, g, "set_cards_per_player", 1, &SetCardPerPlayerPayload{CardsPerPlayer: 1})
+	exec(t, g, "add_card", 1, &AddCardPayload{Text: "alpha"})
+	exec(t, g, "add_card", 2, &AddCardPayload{Text: "bravo"})
+	g.DrawPile.Cards = nil
+
+	events := len(g.Events)
+	err := g.ExecCommand(Command{Name: "draw_card", PlayerID: g.CurrentPlayerID()})
+	if _, ok := err.(EmptyDrawPileErr); !ok {
+		t.Errorf("got error %v, want EmptyDrawPileErr", err)
+	}
+	if len(g.Events) != events {
+		t.Error("rejected draw has been logged")
+	}
+}
+
+func TestLeavingPlayerStartsPlayWhenOthersHaveSubmitted(t *testing.T) {
+	for _, name := range []string{"disconnect_player", "remove_player"} {
+		t.Run(name, func(t *testing.T) {
+			g := newTestGame(t, newTestService(NewRealClock()), "a", "b", "c")
+			exec(t, g, "set_cards_per_player", 1, &SetCardPerPlayerPayload{CardsPerPlayer: 1})
+			exec(t, g, "add_card", 1, &AddCardPayload{Text: "alpha"})
+			exec(t, g, "add_card", 2, &AddCardPayload{Text: "bravo"})
+			var payload interface{} = &PlayerPayload{ID: 3}
+			if name == "remove_player" {
+				payload = &RemovePlayerPayload{ID: 3}
+			}
+			exec(t, g, name, SystemPlayerID, payload)
+			if g.Phase != PlayPhase {
+				t.Errorf("got %s, want %s", g.Phase, PlayPhase)
+			}
+		})
+	}
+}
+
+func TestRoomWithoutConnectedPlayersKeepsWaiting(t *testing.T) {
+	s := newTestService(NewRealClock())
+	g := newTestGame(t, s, "a", "b")
+	exec(t, g, "set_cards_per_player", 1, &SetCardPerPlayerPayload{CardsPerPlayer: 1})
+	exec(t, g, "add_card", 2, &AddCardPayload{Text: "alpha"})
+
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := s.RestoreGame(data, append([]*Event{}, g.Events...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Phase != SubmitPhase {
+		t.Errorf("restored game is in %s, want %s", restored.Phase, SubmitPhase)
+	}
+
+	exec(t, g, "disconnect_player", SystemPlayerID, &PlayerPayload{ID: 2})
+	exec(t, g, "disconnect_player", SystemPlayerID, &PlayerPayload{ID: 1})
+	if g.Phase != SubmitPhase {
+		t.Errorf("game without connected players is in %s, want %s", g.Phase, SubmitPhase)
+	}
+}
+
+func newFilteredService(t *testing.T, action string) *Service {
+	t.Helper()
+	filter, err := NewWordListFilter([]string{"darn"}, action)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewService(NewRealClock(), rand.NewSource(1), filter, logger.NewLogger(""))
+}
+
+func TestEventLogKeepsMaskedText(t *testing.T) {
+	s := newFilteredService(t, MaskContent)
+	g := s.NewGame()
+	exec(t, g, "add_player", SystemPlayerID, &AddPlayerPayload{ID: 1, Name: "darn host"})
+	exec(t, g, "add_player", SystemPlayerID, &AddPlayerPayload{ID: 2, Name: "b"})
+	exec(t, g, "start", 1, nil)
+	exec(t, g, "set_cards_per_player", 1, &SetCardPerPlayerPayload{CardsPerPlayer: 2})
+	exec(t, g, "add_card", 1, &AddCardPayload{Text: "a darn card"})
+	exec(t, g, "edit_card", 1, &EditCardPayload{CardID: g.DrawPile.Cards[0].ID, Text: "an edited darn card"})
+	exec(t, g, "import_cards", 1, &ImportCardsPayload{Format: TextFormat, Data: "darn it\nplain card"})
+
+	for _, event := range g.Events {
+		if bytes.Contains(bytes.ToLower(event.Payload), []byte("darn")) {
+			t.Errorf("event %d %s logs a masked word: %s", event.Seq, event.Name, event.Payload)
+		}
+	}
+	replayed, err := s.Replay(g.RoomID, g.Seed, g.Events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkReplayedState(t, g, replayed)
+}
+
+func TestImportCardsFlagsContent(t *testing.T) {
+	g := newFilteredService(t, FlagContent).NewGame()
+	exec(t, g, "add_player", SystemPlayerID, &AddPlayerPayload{ID: 1, Name: "a"})
+	exec(t, g, "import_cards", 1, &ImportCardsPayload{Format: TextFormat, Data: "darn it\nplain card"})
+
+	if len(g.ContentFlags) != 1 {
+		t.Fatalf("got %d content flags, want 1", len(g.ContentFlags))
+	}
+	if flag := g.ContentFlags[0]; flag.Text != "darn it" || flag.PlayerID != DeckAuthorID || g.DrawPile.Find(flag.CardID) == nil {
+		t.Errorf("got flag %+v, want the imported card", flag)
+	}
+}
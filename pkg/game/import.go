@@ -0,0 +1,140 @@
+package game
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/text/cases"
+)
+
+// Card formats accepted by ParseCards
+const (
+	JSONFormat = "json"
+	CSVFormat  = "csv"
+	TextFormat = "text"
+)
+
+const (
+	// DeckAuthor is the author of imported cards
+	DeckAuthor = "deck"
+	// DeckAuthorID is the author id of imported cards, it never belongs to a player, a spectator or the system
+	DeckAuthorID = -1
+
+	maxImportedCards = 1000
+)
+
+// ErrUnknownFormat occurs when cards are in an unknown format
+var ErrUnknownFormat = errors.New("unknown format")
+
+// ParseCards parses card texts written in the format
+// json: an array of strings or of objects with a text
+// csv: the first column of every record, a header named text is skipped
+// text: one card per line
+func ParseCards(format string, data []byte) ([]string, error) {
+	switch format {
+	case JSONFormat:
+		return parseJSONCards(data)
+	case CSVFormat:
+		return parseCSVCards(data)
+	case TextFormat:
+		return parseTextCards(data)
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+func parseJSONCards(data []byte) ([]string, error) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return nil, err
+	}
+	texts := make([]string, 0, len(raws))
+	for _, raw := range raws {
+		var text string
+		if err := json.Unmarshal(raw, &text); err == nil {
+			texts = append(texts, text)
+			continue
+		}
+		var card struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(raw, &card); err != nil {
+			return nil, err
+		}
+		texts = append(texts, card.Text)
+	}
+	return texts, nil
+}
+
+func parseCSVCards(data []byte) ([]string, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	texts := make([]string, 0)
+	for i := 0; ; i++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) == 0 || (i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "text")) {
+			continue
+		}
+		texts = append(texts, record[0])
+	}
+	return texts, nil
+}
+
+func parseTextCards(data []byte) ([]string, error) {
+	s := bufio.NewScanner(bytes.NewReader(data))
+	texts := make([]string, 0)
+	for s.Scan() {
+		texts = append(texts, s.Text())
+	}
+	return texts, s.Err()
+}
+
+// ImportCards adds cards written by the deck author to the draw pile and returns their texts as they have been added
+// texts rejected by the card validator or the content filter and duplicates are skipped
+// the submit phase is skipped and the game goes to play phase if skipSubmit is true
+func (g *Game) ImportCards(texts []string, skipSubmit bool) []string {
+	seen := make(map[string]bool)
+	for _, card := range g.DrawPile.Cards {
+		seen[cardKey(card.Text)] = true
+	}
+
+	imported := make([]string, 0, len(texts))
+	for _, text := range texts {
+		text, err := g.CardValidator.Normalize(text)
+		text, action := g.FilterText(text)
+		key := cardKey(text)
+		if err != nil || action == RejectContent || seen[key] {
+			continue
+		}
+		seen[key] = true
+		card := NewCard(0, text, DeckAuthor, DeckAuthorID)
+		g.DrawPile.Push(card)
+		if action == FlagContent {
+			g.flagContent(DeckAuthorID, card.ID, card.Text)
+		}
+		imported = append(imported, text)
+	}
+	g.logger.Debugf("%d cards have been imported to the room %s", len(imported), g.RoomID)
+
+	if skipSubmit && g.DrawPile.Len() > 0 {
+		g.shuffleDrawPile()
+		g.Phase = PlayPhase
+	}
+	return imported
+}
+
+// cardKey returns a key to compare card texts regardless of case and spacing
+func cardKey(text string) string {
+	return cases.Fold().String(strings.Join(strings.Fields(text), " "))
+}
@@ -1,16 +1,69 @@
 package game
 
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
 // Player represents a player
 type Player struct {
-	ID                     int    `json:"id"`
-	Name                   string `json:"name"`
-	NumberOfSubmittedCards int    `json:"number_of_submitted_cards"`
+	ID                     int       `json:"id"`
+	Name                   string    `json:"name"`
+	NumberOfSubmittedCards int       `json:"number_of_submitted_cards"`
+	Connected              bool      `json:"connected"`
+	TeamID                 int       `json:"team_id"`
+	Score                  int       `json:"score"`
+	Hand                   []*Card   `json:"hand"`
+	Token                  string    `json:"token"`
+	DisconnectedAt         time.Time `json:"disconnected_at"`
 }
 
 // NewPlayer returns a new Player
 func NewPlayer(id int, name string) *Player {
 	return &Player{
-		ID:   id,
-		Name: name,
+		ID:        id,
+		Name:      name,
+		Connected: true,
+		Token:     newSessionToken(),
 	}
 }
+
+// newSessionToken returns a random token used by a player to reconnect
+func newSessionToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// PlayerState represents a player as seen by every player in the game
+type PlayerState struct {
+	ID                     int    `json:"id"`
+	Name                   string `json:"name"`
+	NumberOfSubmittedCards int    `json:"number_of_submitted_cards"`
+	Connected              bool   `json:"connected"`
+	TeamID                 int    `json:"team_id"`
+	Score                  int    `json:"score"`
+	HandSize               int    `json:"hand_size"`
+}
+
+// State returns a public state of the player, cards in hand are hidden
+func (p Player) State() *PlayerState {
+	return &PlayerState{
+		ID:                     p.ID,
+		Name:                   p.Name,
+		NumberOfSubmittedCards: p.NumberOfSubmittedCards,
+		Connected:              p.Connected,
+		TeamID:                 p.TeamID,
+		Score:                  p.Score,
+		HandSize:               len(p.Hand),
+	}
+}
+
+// Spectator represents a client who watches the game without playing
+type Spectator struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
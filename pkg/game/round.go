@@ -0,0 +1,93 @@
+package game
+
+import "sort"
+
+const maxRounds = 10
+
+// Round represents a round of the game, every round plays the same cards with its own rule
+type Round struct {
+	Name string `json:"name"`
+	Rule string `json:"rule"`
+}
+
+// DefaultRounds returns the rounds of the classic game
+func DefaultRounds() []*Round {
+	return []*Round{
+		{Name: "Describe", Rule: "Use any words except the ones on the card"},
+		{Name: "One Word", Rule: "Use only one word"},
+		{Name: "Charades", Rule: "Act it out without saying a word"},
+	}
+}
+
+func isValidRounds(rounds []*Round) bool {
+	if len(rounds) == 0 || len(rounds) > maxRounds {
+		return false
+	}
+	for _, round := range rounds {
+		if round == nil || round.Name == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// TeamResult represents a final score of a team
+type TeamResult struct {
+	TeamID      int    `json:"team_id"`
+	Name        string `json:"name"`
+	Score       int    `json:"score"`
+	RoundScores []int  `json:"round_scores"`
+}
+
+// PlayerResult represents a final score of a player
+type PlayerResult struct {
+	PlayerID int    `json:"player_id"`
+	Name     string `json:"name"`
+	Score    int    `json:"score"`
+}
+
+// Results represents results of a finished game
+type Results struct {
+	Teams         []*TeamResult   `json:"teams"`
+	Players       []*PlayerResult `json:"players"`
+	WinnerTeamIDs []int           `json:"winner_team_ids"`
+}
+
+// Results returns results of the game ranked by score
+func (g Game) Results() *Results {
+	results := &Results{
+		Teams:         make([]*TeamResult, 0, len(g.Teams)),
+		Players:       make([]*PlayerResult, 0, len(g.Players)),
+		WinnerTeamIDs: make([]int, 0),
+	}
+	for _, team := range g.sortedTeams() {
+		results.Teams = append(results.Teams, &TeamResult{
+			TeamID:      team.ID,
+			Name:        team.Name,
+			Score:       team.Score,
+			RoundScores: team.RoundScores,
+		})
+	}
+	sort.SliceStable(results.Teams, func(i, j int) bool { return results.Teams[i].Score > results.Teams[j].Score })
+	for _, team := range results.Teams {
+		if team.Score == results.Teams[0].Score {
+			results.WinnerTeamIDs = append(results.WinnerTeamIDs, team.TeamID)
+		}
+	}
+
+	for _, player := range g.Players {
+		results.Players = append(results.Players, &PlayerResult{
+			PlayerID: player.ID,
+			Name:     player.Name,
+			Score:    player.Score,
+		})
+	}
+	sort.Slice(results.Players, func(i, j int) bool {
+		if results.Players[i].Score != results.Players[j].Score {
+			return results.Players[i].Score > results.Players[j].Score
+		}
+		return results.Players[i].PlayerID < results.Players[j].PlayerID
+	})
+
+	return results
+}
@@ -1,20 +1,92 @@
 package game
 
-import "whatthecard/pkg/logger"
+import (
+	"encoding/json"
+	"math/rand"
+	"sort"
+	"sync"
+	"whatthecard/pkg/logger"
+)
 
 // Service represents a game service
 type Service struct {
-	logger *logger.Logger
+	clock         Clock
+	mu            sync.Mutex
+	rand          *rand.Rand
+	contentFilter ContentFilter
+	logger        *logger.Logger
 }
 
 // NewService returns a new GameService
-func NewService(logger *logger.Logger) *Service {
+// source is used to pick a seed for every new game, contentFilter screens the cards and the player names of every game if not nil
+func NewService(clock Clock, source rand.Source, contentFilter ContentFilter, logger *logger.Logger) *Service {
 	return &Service{
-		logger: logger,
+		clock:         clock,
+		rand:          rand.New(source),
+		contentFilter: contentFilter,
+		logger:        logger,
 	}
 }
 
-// NewGame returns a new Game
+// NewGame returns a new Game with a seed picked from the service's random source
 func (s *Service) NewGame() *Game {
-	return NewGame(s.logger)
+	s.mu.Lock()
+	seed := s.rand.Int63()
+	s.mu.Unlock()
+	return s.NewGameWithSeed(seed)
+}
+
+// NewGameWithSeed returns a new Game with the given seed, games with the same seed and commands shuffle the same
+func (s *Service) NewGameWithSeed(seed int64) *Game {
+	g := NewGame(seed, s.logger)
+	g.clock = s.clock
+	g.contentFilter = s.contentFilter
+	return g
+}
+
+// RestoreGame returns a Game restored from its marshaled snapshot and its event log
+// every connected player is disconnected with a logged disconnect_player command
+func (s *Service) RestoreGame(data []byte, events []*Event) (*Game, error) {
+	g := s.NewGame()
+	if err := json.Unmarshal(data, g); err != nil {
+		return nil, err
+	}
+	if events != nil {
+		g.Events = events
+	}
+	if g.BannedNames == nil {
+		g.BannedNames = make(map[string]bool)
+	}
+	if g.BannedTokens == nil {
+		g.BannedTokens = make(map[string]bool)
+	}
+	ids := make([]int, 0, len(g.Players))
+	for id := range g.Players {
+		if id > g.lastPlayerID {
+			g.lastPlayerID = id
+		}
+		ids = append(ids, id)
+	}
+	// players who have submitted every card go first, so the players left connected never all have submitted
+	// and the restore cannot start the play phase on its own
+	sort.Slice(ids, func(i, j int) bool {
+		done := func(id int) bool {
+			return g.Players[id].NumberOfSubmittedCards >= g.CardsPerPlayer
+		}
+		if done(ids[i]) != done(ids[j]) {
+			return done(ids[i])
+		}
+		return ids[i] < ids[j]
+	})
+	// disconnecting goes through the log, otherwise a replay would keep these players connected
+	for _, id := range ids {
+		if !g.Players[id].Connected {
+			continue
+		}
+		cmd := Command{Name: "disconnect_player", PlayerID: SystemPlayerID, Payload: &PlayerPayload{ID: id}}
+		if err := g.ExecCommand(cmd); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
 }
@@ -0,0 +1,65 @@
+package game
+
+import "encoding/json"
+
+// pileSnapshot is a Pile without its methods, so it can be marshaled by the default encoder
+type pileSnapshot Pile
+
+// MarshalJSON marshals the pile including its last card id
+func (p *Pile) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		*pileSnapshot
+		LastCardID int `json:"last_card_id"`
+	}{
+		pileSnapshot: (*pileSnapshot)(p),
+		LastCardID:   p.lastCardID,
+	})
+}
+
+// UnmarshalJSON unmarshals the pile including its last card id
+func (p *Pile) UnmarshalJSON(data []byte) error {
+	v := struct {
+		*pileSnapshot
+		LastCardID int `json:"last_card_id"`
+	}{
+		pileSnapshot: (*pileSnapshot)(p),
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	p.lastCardID = v.LastCardID
+	return nil
+}
+
+// gameSnapshot is a Game without its methods, so it can be marshaled by the default encoder
+type gameSnapshot Game
+
+// MarshalJSON marshals every field needed to restore the game
+func (g *Game) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		*gameSnapshot
+		LastTeamID   int
+		LastPlayerID int
+	}{
+		gameSnapshot: (*gameSnapshot)(g),
+		LastTeamID:   g.lastTeamID,
+		LastPlayerID: g.lastPlayerID,
+	})
+}
+
+// UnmarshalJSON restores the game marshaled by MarshalJSON
+func (g *Game) UnmarshalJSON(data []byte) error {
+	v := struct {
+		*gameSnapshot
+		LastTeamID   int
+		LastPlayerID int
+	}{
+		gameSnapshot: (*gameSnapshot)(g),
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	g.lastTeamID = v.LastTeamID
+	g.lastPlayerID = v.LastPlayerID
+	return nil
+}
@@ -0,0 +1,112 @@
+package game
+
+import (
+	"sort"
+	"time"
+)
+
+// Outcomes of a draw that puts the card back to the draw pile
+const (
+	PassedOutcome   = "passed"
+	ReturnedOutcome = "returned"
+)
+
+// Draw represents a card drawn by a player and how it was resolved
+type Draw struct {
+	CardID     int       `json:"card_id"`
+	Text       string    `json:"text"`
+	PlayerID   int       `json:"player_id"`
+	PlayerName string    `json:"player_name"`
+	Round      int       `json:"round"`
+	Outcome    string    `json:"outcome"`
+	DrawnAt    time.Time `json:"drawn_at"`
+	ResolvedAt time.Time `json:"resolved_at"`
+	Seconds    float64   `json:"seconds"`
+}
+
+// AuthorSummary represents the cards submitted by a player
+type AuthorSummary struct {
+	AuthorID int     `json:"author_id"`
+	Author   string  `json:"author"`
+	Cards    []*Card `json:"cards"`
+}
+
+// Summary represents a summary of a finished game
+type Summary struct {
+	Authors []*AuthorSummary `json:"authors"`
+	Draws   []*Draw          `json:"draws"`
+	Results *Results         `json:"results"`
+}
+
+// recordDraw records the card drawn by the player
+func (g *Game) recordDraw(player *Player, card *Card) {
+	g.Draws = append(g.Draws, &Draw{
+		CardID:     card.ID,
+		Text:       card.Text,
+		PlayerID:   player.ID,
+		PlayerName: player.Name,
+		Round:      g.Round,
+		DrawnAt:    g.clock.Now(),
+	})
+}
+
+// resolveDraw records how the last draw of the card has been resolved
+func (g *Game) resolveDraw(cardID int, outcome string) {
+	for i := len(g.Draws) - 1; i >= 0; i-- {
+		draw := g.Draws[i]
+		if draw.CardID != cardID {
+			continue
+		}
+		if draw.ResolvedAt.IsZero() {
+			draw.Outcome = outcome
+			draw.ResolvedAt = g.clock.Now()
+			draw.Seconds = draw.ResolvedAt.Sub(draw.DrawnAt).Seconds()
+		}
+		return
+	}
+}
+
+// EndGame returns every card in hand to the draw pile and finishes the game
+func (g *Game) EndGame() {
+	for id := range g.Players {
+		g.returnHand(id)
+	}
+	g.Phase = FinishedPhase
+	g.TurnDeadline = time.Time{}
+	g.logger.Debugf("game in the room %s has finished", g.RoomID)
+}
+
+// Summary returns the cards of every author, every draw and the results of the game
+func (g Game) Summary() *Summary {
+	authors := make(map[int]*AuthorSummary)
+	addCards := func(cards []*Card) {
+		for _, card := range cards {
+			author, ok := authors[card.AuthorID]
+			if !ok {
+				author = &AuthorSummary{AuthorID: card.AuthorID, Author: card.Author, Cards: make([]*Card, 0)}
+				authors[card.AuthorID] = author
+			}
+			author.Cards = append(author.Cards, card)
+		}
+	}
+	addCards(g.DrawPile.Cards)
+	addCards(g.DiscardPile.Cards)
+	for _, player := range g.Players {
+		addCards(player.Hand)
+	}
+
+	summary := &Summary{
+		Authors: make([]*AuthorSummary, 0, len(authors)),
+		Draws:   g.Draws,
+		Results: g.Results(),
+	}
+	for _, author := range authors {
+		sort.Slice(author.Cards, func(i, j int) bool { return author.Cards[i].ID < author.Cards[j].ID })
+		summary.Authors = append(summary.Authors, author)
+	}
+	sort.Slice(summary.Authors, func(i, j int) bool { return summary.Authors[i].AuthorID < summary.Authors[j].AuthorID })
+	if summary.Draws == nil {
+		summary.Draws = make([]*Draw, 0)
+	}
+	return summary
+}
@@ -0,0 +1,27 @@
+package game
+
+// Team represents a team of players
+type Team struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+	// RoundScores holds the score of each round
+	RoundScores []int `json:"round_scores"`
+}
+
+// NewTeam returns a new Team
+func NewTeam(id int, name string) *Team {
+	return &Team{
+		ID:   id,
+		Name: name,
+	}
+}
+
+// AddScore adds a point to the team in the given round
+func (t *Team) AddScore(round int) {
+	for len(t.RoundScores) <= round {
+		t.RoundScores = append(t.RoundScores, 0)
+	}
+	t.RoundScores[round]++
+	t.Score++
+}
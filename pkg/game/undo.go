@@ -0,0 +1,55 @@
+package game
+
+import "encoding/json"
+
+// maxUndoDepth is the number of commands the host can undo in a row
+const maxUndoDepth = 10
+
+// undoableCommands are the commands the host can undo
+var undoableCommands = map[string]bool{
+	"draw_card":            true,
+	"add_card":             true,
+	"import_cards":         true,
+	"reset":                true,
+	"set_cards_per_player": true,
+}
+
+// readOnlyCommands do not change the board, so they keep the undo history
+var readOnlyCommands = map[string]bool{
+	"export": true,
+}
+
+// snapshot marshals the board, the event log and the undo history are not part of it
+func (g *Game) snapshot() ([]byte, error) {
+	return json.Marshal(g)
+}
+
+// pushHistory keeps the board taken before an undoable command, dropping the oldest one above maxUndoDepth
+func (g *Game) pushHistory(snapshot []byte) {
+	g.history = append(g.history, snapshot)
+	if len(g.history) > maxUndoDepth {
+		g.history = g.history[len(g.history)-maxUndoDepth:]
+	}
+}
+
+// CanUndo checks if there is a command to undo
+func (g Game) CanUndo() bool {
+	return len(g.history) > 0
+}
+
+// Undo restores the board as it was before the last undoable command
+func (g *Game) Undo() error {
+	snapshot := g.history[len(g.history)-1]
+	restored := &Game{}
+	if err := json.Unmarshal(snapshot, restored); err != nil {
+		return err
+	}
+	restored.Events = g.Events
+	restored.history = g.history[:len(g.history)-1]
+	restored.lastPlayerID = g.lastPlayerID
+	restored.contentFilter = g.contentFilter
+	restored.clock = g.clock
+	restored.logger = g.logger
+	*g = *restored
+	return nil
+}
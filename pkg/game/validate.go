@@ -0,0 +1,89 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// DefaultMinCardLength is the minimum number of characters of a card text by default
+	DefaultMinCardLength = 1
+	// DefaultMaxCardLength is the maximum number of characters of a card text by default
+	DefaultMaxCardLength = 100
+
+	maxCardLength = 500
+)
+
+// Card text errors
+var (
+	ErrCardTooShort = errors.New("card text is too short")
+	ErrCardTooLong  = errors.New("card text is too long")
+)
+
+// CardValidator validates and normalizes card texts
+type CardValidator struct {
+	MinLength int `json:"min_length"`
+	MaxLength int `json:"max_length"`
+}
+
+// DefaultCardValidator returns a validator with the default lengths
+func DefaultCardValidator() CardValidator {
+	return CardValidator{
+		MinLength: DefaultMinCardLength,
+		MaxLength: DefaultMaxCardLength,
+	}
+}
+
+// isValid checks if the lengths can be used by a validator
+func (v CardValidator) isValid() bool {
+	return v.MinLength >= 1 && v.MinLength <= v.MaxLength && v.MaxLength <= maxCardLength
+}
+
+// Normalize returns the text in NFC without control characters and surrounding spaces
+// line breaks and tabs become spaces, the length is counted in characters after normalization
+func (v CardValidator) Normalize(text string) (string, error) {
+	text = norm.NFC.String(text)
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+			return ' '
+		case unicode.IsControl(r):
+			return -1
+		default:
+			return r
+		}
+	}, text)
+	text = strings.TrimSpace(text)
+
+	n := utf8.RuneCountInString(text)
+	if n < v.MinLength {
+		return "", ErrCardTooShort
+	}
+	if n > v.MaxLength {
+		return "", ErrCardTooLong
+	}
+	return text, nil
+}
+
+// InvalidCardTextErr occurs when a card text is rejected by the card validator
+type InvalidCardTextErr struct {
+	cmd       Command
+	err       error
+	validator CardValidator
+}
+
+func (e InvalidCardTextErr) Error() string {
+	return fmt.Sprintf("cmd: %s: %v, it must have %d to %d characters", e.cmd.Name, e.err, e.validator.MinLength, e.validator.MaxLength)
+}
+
+func (e InvalidCardTextErr) Code() string {
+	if e.err == ErrCardTooLong {
+		return "card_too_long"
+	}
+	return "card_too_short"
+}
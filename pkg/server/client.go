@@ -1,6 +1,9 @@
 package server
 
 import (
+	"encoding/json"
+	"errors"
+	"sync"
 	"time"
 	"whatthecard/pkg/logger"
 
@@ -16,28 +19,45 @@ const (
 
 	// Send pings to peer with this period. Must be less than pongWait.
 	pingPeriod = (pongWait * 9) / 10
+
+	// Maximum number of messages waiting to be written to the peer.
+	sendBufferSize = 64
 )
 
+// ErrClientTooSlow occurs when a client does not read its messages fast enough
+var ErrClientTooSlow = errors.New("client is too slow, the connection has been closed")
+
+// Conn is the connection of a client, it is implemented by *websocket.Conn
+type Conn interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+	SetPongHandler(h func(appData string) error)
+	Close() error
+}
+
 // Client provides a websocket connection
 type Client struct {
-	conn   *websocket.Conn
+	conn   Conn
 	send   chan []byte
+	mu     sync.Mutex
+	closed bool
 	logger *logger.Logger
 }
 
 // NewClient returns a new Client
-func NewClient(conn *websocket.Conn, logger *logger.Logger) *Client {
+func NewClient(conn Conn, logger *logger.Logger) *Client {
 	return &Client{
 		conn:   conn,
 		logger: logger,
-		send:   make(chan []byte),
+		send:   make(chan []byte, sendBufferSize),
 	}
 }
 
-// ReadPump reads for an incomming message
-func (c *Client) ReadPump() {
-	defer close(c.send)
-
+// ReadPump reads for an incomming message and passes it to the handler until the connection is closed
+func (c *Client) ReadPump(handle func([]byte)) {
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error {
 		c.conn.SetReadDeadline(time.Now().Add(pongWait))
 		return nil
@@ -45,20 +65,78 @@ func (c *Client) ReadPump() {
 
 	for {
 		_, message, err := c.conn.ReadMessage()
-		c.logger.Debug(string(message))
-		c.conn.SetReadDeadline(time.Now().Add(pongWait))
 		if err != nil {
-			c.logger.Error(err)
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				c.logger.Error(err)
+			}
 			break
 		}
-		c.send <- message
+		c.logger.Debug(string(message))
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		handle(message)
 	}
 }
 
-// WriteJSON writes a JSON to the client
-func (c *Client) WriteJSON(v interface{}) {
-	err := c.conn.WriteJSON(v)
+// WritePump writes queued messages and pings to the peer until the client is closed
+func (c *Client) WritePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				c.logger.Error(err)
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.logger.Error(err)
+				return
+			}
+		}
+	}
+}
+
+// SendJSON queues a JSON message to the client, messages to a closed client are dropped
+// a client whose queue is full is closed instead of blocking the sender
+func (c *Client) SendJSON(v interface{}) error {
+	message, err := json.Marshal(v)
 	if err != nil {
-		c.logger.Error(err)
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	select {
+	case c.send <- message:
+		return nil
+	default:
+		c.closed = true
+		close(c.send)
+		return ErrClientTooSlow
+	}
+}
+
+// Close closes the client, queued messages are written before the connection is closed
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
 	}
+	c.closed = true
+	close(c.send)
 }
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+	"whatthecard/pkg/deck"
+	"whatthecard/pkg/game"
+
+	"github.com/gorilla/mux"
+)
+
+// DeckRequest represents a request body to create or update a deck
+// a deck can be created from the cards of a finished room instead of a list of cards
+type DeckRequest struct {
+	Name   string       `json:"name"`
+	Cards  []*game.Card `json:"cards"`
+	RoomID string       `json:"room_id"`
+}
+
+func (s *Server) handleListDecks(w http.ResponseWriter, r *http.Request) {
+	if !s.checkDecks(w) {
+		return
+	}
+	decks, err := s.decks.List()
+	if err != nil {
+		s.logger.Error(err)
+		writeError(w, "failed to read the decks", http.StatusInternalServerError)
+		return
+	}
+	infos := make([]*deck.Info, 0, len(decks))
+	for _, d := range decks {
+		infos = append(infos, d.Info())
+	}
+	writeJSON(w, infos)
+}
+
+func (s *Server) handleCreateDeck(w http.ResponseWriter, r *http.Request) {
+	if !s.checkDecks(w) {
+		return
+	}
+	req, ok := s.decodeDeckRequest(w, r)
+	if !ok {
+		return
+	}
+	d, err := deck.NewDeck(req.Name, req.Cards, time.Now())
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.decks.Save(d); err != nil {
+		s.logger.Error(err)
+		writeError(w, "failed to save the deck", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(d)
+}
+
+func (s *Server) handleGetDeck(w http.ResponseWriter, r *http.Request) {
+	if !s.checkDecks(w) {
+		return
+	}
+	d, ok := s.getDeck(w, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	writeJSON(w, d)
+}
+
+func (s *Server) handleUpdateDeck(w http.ResponseWriter, r *http.Request) {
+	if !s.checkDecks(w) {
+		return
+	}
+	d, ok := s.getDeck(w, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	req, ok := s.decodeDeckRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := d.SetCards(req.Name, req.Cards); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	d.UpdatedAt = time.Now()
+	if err := s.decks.Save(d); err != nil {
+		s.logger.Error(err)
+		writeError(w, "failed to save the deck", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, d)
+}
+
+func (s *Server) handleDeleteDeck(w http.ResponseWriter, r *http.Request) {
+	if !s.checkDecks(w) {
+		return
+	}
+	err := s.decks.Delete(mux.Vars(r)["id"])
+	if err == deck.ErrDeckNotFound {
+		writeError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error(err)
+		writeError(w, "failed to delete the deck", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkDecks writes an error if the deck library is disabled
+func (s *Server) checkDecks(w http.ResponseWriter) bool {
+	if s.decks == nil {
+		writeError(w, "the deck library is disabled", http.StatusNotImplemented)
+		return false
+	}
+	return true
+}
+
+func (s *Server) getDeck(w http.ResponseWriter, id string) (*deck.Deck, bool) {
+	d, err := s.decks.Get(id)
+	if err == deck.ErrDeckNotFound {
+		writeError(w, err.Error(), http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		s.logger.Error(err)
+		writeError(w, "failed to read the deck", http.StatusInternalServerError)
+		return nil, false
+	}
+	return d, true
+}
+
+// decodeDeckRequest decodes the request body, the cards of the room are used if a room id is given
+func (s *Server) decodeDeckRequest(w http.ResponseWriter, r *http.Request) (*DeckRequest, bool) {
+	req := &DeckRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+	if req.RoomID == "" {
+		return req, true
+	}
+
+	room := s.hub.GetRoom(strings.ToLower(req.RoomID))
+	if room == nil {
+		writeError(w, "room not found", http.StatusNotFound)
+		return nil, false
+	}
+	finished := false
+	if !room.Do(func() {
+		finished = room.game.Phase == game.FinishedPhase
+		if finished {
+			req.Cards = make([]*game.Card, 0)
+			for _, card := range room.game.ExportCards() {
+				req.Cards = append(req.Cards, game.NewCard(card.ID, card.Text, card.Author, card.AuthorID))
+			}
+		}
+	}) {
+		writeError(w, "room not found", http.StatusNotFound)
+		return nil, false
+	}
+	if !finished {
+		writeError(w, "a deck can be saved from a room once the game has finished", http.StatusConflict)
+		return nil, false
+	}
+	return req, true
+}
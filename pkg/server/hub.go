@@ -1,9 +1,13 @@
 package server
 
 import (
-	"math/rand"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 	"whatthecard/pkg/game"
 	"whatthecard/pkg/logger"
@@ -12,20 +16,51 @@ import (
 	"github.com/gorilla/websocket"
 )
 
-// RoomIDLength is the length of a room id
-const RoomIDLength = 4
+// maxRoomIDAttempts is how many ids are generated before giving up on finding an unused one
+const maxRoomIDAttempts = 100
+
+// Hub option defaults
+const (
+	DefaultMaxRooms     = 1000
+	DefaultEmptyRoomTTL = 10 * time.Minute
+	DefaultIdleRoomTTL  = 2 * time.Hour
+)
+
+// Hub errors
+var (
+	ErrNoRoomID     = errors.New("failed to generate an unused room id")
+	ErrTooManyRooms = errors.New("too many rooms")
+)
+
+// HubOptions represents server wide limits of rooms
+type HubOptions struct {
+	// MaxRooms is the maximum number of concurrent rooms, 0 means no limit
+	MaxRooms int
+	// EmptyRoomTTL is how long a room without clients is kept, 0 means forever
+	EmptyRoomTTL time.Duration
+	// IdleRoomTTL is how long a room without commands is kept, 0 means forever
+	IdleRoomTTL time.Duration
+}
 
 // Hub is central handler for websocket connections
 type Hub struct {
+	mu       sync.Mutex
 	rooms    map[string]*Room
+	ids      *RoomIDGenerator
+	options  HubOptions
 	upgrader websocket.Upgrader
+	store    RoomStore
 	logger   *logger.Logger
 }
 
-// NewHub returns a new Hub
-func NewHub(logger *logger.Logger) *Hub {
+// NewHub returns a new Hub, store can be nil if rooms do not need to be persisted
+// ids is used to generate room ids
+func NewHub(store RoomStore, ids *RoomIDGenerator, options HubOptions, logger *logger.Logger) *Hub {
 	return &Hub{
-		rooms: make(map[string]*Room),
+		rooms:   make(map[string]*Room),
+		ids:     ids,
+		options: options,
+		store:   store,
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
@@ -36,52 +71,181 @@ func NewHub(logger *logger.Logger) *Hub {
 }
 
 // GetRoom returns a room with the given room id
-func (h Hub) GetRoom(id string) *Room {
+func (h *Hub) GetRoom(id string) *Room {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	return h.rooms[id]
 }
 
-// CreateRoom creates a new room
-func (h *Hub) CreateRoom(game *game.Game, logger *logger.Logger) *Room {
-	for {
-		id := randString(RoomIDLength)
+// CreateRoom creates a new room with an unused id and starts its run loop
+func (h *Hub) CreateRoom(game *game.Game, options RoomOptions, logger *logger.Logger) (*Room, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.options.MaxRooms > 0 && len(h.rooms) >= h.options.MaxRooms {
+		return nil, ErrTooManyRooms
+	}
+	for i := 0; i < maxRoomIDAttempts; i++ {
+		id, err := h.ids.Generate()
+		if err != nil {
+			return nil, err
+		}
 		_, ok := h.rooms[id]
 		if !ok {
-			h.rooms[id] = NewRoom(id, game, logger)
+			room := NewRoom(id, game, options, h.store, logger)
+			room.onEmpty = h.deleteRoom
 			game.RoomID = id
+			room.Save()
+			h.rooms[id] = room
+			go room.Run()
 			h.logger.Debugf("room %s has been created", id)
-			return h.rooms[id]
+			return room, nil
+		}
+	}
+	return nil, ErrNoRoomID
+}
+
+// LoadRooms restores every room saved in the store
+func (h *Hub) LoadRooms(gameService *game.Service) error {
+	if h.store == nil {
+		return nil
+	}
+	rooms, err := h.store.LoadRooms()
+	if err != nil {
+		return err
+	}
+	for id, data := range rooms {
+		snapshot := roomSnapshot{}
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			h.logger.Errorf("failed to load room %s: %v", id, err)
+			continue
+		}
+		events, err := h.loadLog(id)
+		if err != nil {
+			h.logger.Errorf("failed to load the log of room %s: %v", id, err)
+			continue
+		}
+		g, err := gameService.RestoreGame(snapshot.Game, events)
+		if err != nil {
+			h.logger.Errorf("failed to load room %s: %v", id, err)
+			continue
+		}
+		g.RoomID = id
+		room := NewRoom(id, g, snapshot.Options, h.store, h.logger)
+		room.loggedEvents = len(events)
+		room.onEmpty = h.deleteRoom
+		room.lastClientID = g.LastPlayerID()
+		room.Save()
+		for playerID := range g.Players {
+			room.ScheduleRemoval(playerID)
+		}
+		room.ScheduleTurnTimer()
+		h.mu.Lock()
+		h.rooms[id] = room
+		h.mu.Unlock()
+		go room.Run()
+		h.logger.Debugf("room %s has been restored", id)
+	}
+	return nil
+}
+
+// loadLog reads the events appended to the log of a room
+func (h *Hub) loadLog(id string) ([]*game.Event, error) {
+	data, err := h.store.LoadLog(id)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]*game.Event, 0)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		event := &game.Event{}
+		err := dec.Decode(event)
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
 		}
+		events = append(events, event)
 	}
 }
 
-func randString(n int) string {
-	const letterBytes = "abcdefghijklmnopqrstuvwxyz"
-	b := make([]byte, n)
-	s := rand.NewSource(time.Now().UnixNano())
-	r := rand.New(s)
-	for i := range b {
-		b[i] = letterBytes[r.Intn(len(letterBytes))]
+// RunJanitor deletes expired rooms every interval, it blocks forever
+func (h *Hub) RunJanitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.DeleteExpiredRooms()
 	}
-	return string(b)
+}
+
+// DeleteExpiredRooms deletes rooms that have been empty or idle for longer than the options allow
+func (h *Hub) DeleteExpiredRooms() {
+	h.mu.Lock()
+	rooms := make([]*Room, 0, len(h.rooms))
+	for _, room := range h.rooms {
+		rooms = append(rooms, room)
+	}
+	h.mu.Unlock()
+
+	for _, room := range rooms {
+		room := room
+		room.Do(func() {
+			if room.isExpired(h.options.EmptyRoomTTL, h.options.IdleRoomTTL) {
+				h.logger.Debugf("room %s has expired", room.ID)
+				h.deleteRoom(room)
+			}
+		})
+	}
+}
+
+// deleteRoom removes the room from the hub and stops it, it is called from the room's run loop
+func (h *Hub) deleteRoom(room *Room) {
+	h.mu.Lock()
+	if h.rooms[room.ID] == room {
+		delete(h.rooms, room.ID)
+	}
+	h.mu.Unlock()
+
+	room.Delete()
+	room.Stop()
+	h.logger.Debugf("room %s has been deleted", room.ID)
 }
 
 // HandleWS handles websocket connection
+// a client with the session_token of a player in the room is reattached to that player
+// a client with role=spectator watches the game without joining it
 func (h *Hub) HandleWS(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	roomID := strings.ToLower(vars["id"])
 	if roomID == "" {
 		writeError(w, "room id is required", http.StatusBadRequest)
-	}
-
-	playerName := r.URL.Query().Get("player_name")
-	if playerName == "" {
-		writeError(w, "player_name is required", http.StatusBadRequest)
 		return
 	}
 
 	room := h.GetRoom(roomID)
 	if room == nil {
 		h.logger.Debug("room not found:", roomID)
+		writeError(w, "room not found", http.StatusNotFound)
+		return
+	}
+
+	token := r.URL.Query().Get("session_token")
+	playerName := r.URL.Query().Get("player_name")
+	password := r.URL.Query().Get("password")
+	spectator := r.URL.Query().Get("role") == "spectator"
+	if playerName == "" && !spectator {
+		var player *game.Player
+		room.Do(func() {
+			player = room.game.PlayerByToken(token)
+		})
+		if player == nil {
+			writeError(w, "player_name is required", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := room.CanJoin(playerName, token, password, spectator); err != nil {
+		writeError(w, err.Error(), joinErrorStatus(err))
 		return
 	}
 
@@ -92,19 +256,31 @@ func (h *Hub) HandleWS(w http.ResponseWriter, r *http.Request) {
 	}
 
 	client := NewClient(conn, h.logger)
-	clientID := room.Join(client)
-	player := room.game.AddPlayer(clientID, playerName)
-	room.BroadcastState()
-
-	go room.WritePump(clientID)
-	client.ReadPump()
+	clientID, err := room.Join(client, playerName, token, password, spectator)
+	if err != nil {
+		h.logger.Error(err)
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()))
+		conn.Close()
+		return
+	}
 
-	room.Leave(clientID)
-	room.game.RemovePlayer(player.ID)
-	room.BroadcastState()
+	go client.WritePump()
+	client.ReadPump(func(message []byte) {
+		room.Receive(clientID, client, message)
+	})
+	room.Leave(clientID, client)
+}
 
-	if room.TotalClient == 0 {
-		delete(h.rooms, room.ID)
-		h.logger.Debugf("room %s has been deleted", room.ID)
+// joinErrorStatus returns the http status code of an error from joining a room
+func joinErrorStatus(err error) int {
+	switch err {
+	case ErrWrongPassword:
+		return http.StatusUnauthorized
+	case ErrRoomFull, ErrGameStarted, ErrBanned:
+		return http.StatusForbidden
+	case ErrRoomClosed:
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
 	}
 }
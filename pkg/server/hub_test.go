@@ -0,0 +1,51 @@
+package server
+
+import (
+	"math/rand"
+	"testing"
+	"whatthecard/pkg/game"
+	"whatthecard/pkg/logger"
+)
+
+func TestLoadRoomsDoesNotReuseRemovedPlayerIDs(t *testing.T) {
+	l := logger.NewLogger("")
+	store, err := NewFileRoomStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := game.NewService(game.NewRealClock(), rand.NewSource(1), nil, l)
+	g := s.NewGame()
+	for id := 1; id <= 3; id++ {
+		cmd := game.Command{Name: "add_player", PlayerID: game.SystemPlayerID, Payload: &game.AddPlayerPayload{ID: id, Name: "player"}}
+		if err := g.ExecCommand(cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := game.Command{Name: "remove_player", PlayerID: game.SystemPlayerID, Payload: &game.RemovePlayerPayload{ID: 3}}
+	if err := g.ExecCommand(cmd); err != nil {
+		t.Fatal(err)
+	}
+	NewRoom("room", g, DefaultRoomOptions(), store, l).Save()
+
+	hub := NewHub(store, nil, HubOptions{}, l)
+	if err := hub.LoadRooms(s); err != nil {
+		t.Fatal(err)
+	}
+	room := hub.GetRoom("room")
+	if room == nil {
+		t.Fatal("room has not been restored")
+	}
+	t.Cleanup(room.Stop)
+	var lastClientID, events int
+	room.Do(func() {
+		lastClientID = room.lastClientID
+		events = len(room.game.Events)
+	})
+	if lastClientID != 3 {
+		t.Errorf("got last client id %d, want 3", lastClientID)
+	}
+	// the 4 saved events are followed by a disconnect_player event for each restored player
+	if events != 6 {
+		t.Errorf("got %d restored events, want 6", events)
+	}
+}
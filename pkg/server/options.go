@@ -0,0 +1,76 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+)
+
+const maxPlayersLimit = 100
+
+// Join errors
+var (
+	ErrRoomFull      = errors.New("room is full")
+	ErrWrongPassword = errors.New("wrong password")
+	ErrGameStarted   = errors.New("game has already started, joining is not allowed")
+	ErrBanned        = errors.New("you have been banned from this room")
+	ErrNameRejected  = errors.New("player name is not allowed")
+)
+
+// RoomOptions represents options of a room set by its creator
+type RoomOptions struct {
+	// MaxPlayers is the maximum number of players, 0 means no limit
+	MaxPlayers int `json:"max_players"`
+	// PasswordHash is the sha256 hash of the room password, empty means no password
+	PasswordHash string `json:"password_hash"`
+	// AllowLateJoin allows new players to join after the submit phase
+	AllowLateJoin bool `json:"allow_late_join"`
+}
+
+// CreateRoomRequest represents a request body to create a room
+type CreateRoomRequest struct {
+	MaxPlayers    int    `json:"max_players"`
+	Password      string `json:"password"`
+	AllowLateJoin *bool  `json:"allow_late_join"`
+	// Seed starts the game with a fixed seed, it is only allowed in debug mode
+	Seed *int64 `json:"seed"`
+	// DeckID fills the draw pile with the cards of a saved deck
+	DeckID string `json:"deck_id"`
+}
+
+// DefaultRoomOptions returns options of a room without any restriction
+func DefaultRoomOptions() RoomOptions {
+	return RoomOptions{
+		AllowLateJoin: true,
+	}
+}
+
+// RoomOptions validates the request and returns options of the room to be created
+func (req CreateRoomRequest) RoomOptions() (RoomOptions, error) {
+	options := DefaultRoomOptions()
+	if req.MaxPlayers < 0 || req.MaxPlayers > maxPlayersLimit {
+		return options, errors.New("max_players must be between 0 and 100")
+	}
+	options.MaxPlayers = req.MaxPlayers
+	if req.Password != "" {
+		options.PasswordHash = hashPassword(req.Password)
+	}
+	if req.AllowLateJoin != nil {
+		options.AllowLateJoin = *req.AllowLateJoin
+	}
+	return options, nil
+}
+
+// CheckPassword checks if the password matches the room password
+func (o RoomOptions) CheckPassword(password string) bool {
+	if o.PasswordHash == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(hashPassword(password)), []byte(o.PasswordHash)) == 1
+}
+
+func hashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
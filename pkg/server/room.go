@@ -1,142 +1,645 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 	"whatthecard/pkg/game"
 	"whatthecard/pkg/logger"
-
-	"github.com/gorilla/websocket"
 )
 
+// reconnectGracePeriod is how long a disconnected player keeps their seat
+const reconnectGracePeriod = 2 * time.Minute
+
+// ErrRoomClosed occurs when a client try to use a room that has been closed
+var ErrRoomClosed = errors.New("room has been closed")
+
+// ErrInvalidSessionToken occurs when a session token does not belong to any player of the room
+var ErrInvalidSessionToken = errors.New("invalid session token")
+
 // Room represents a client room
+// every change to the clients and the game is serialized by the room's run loop
 type Room struct {
 	ID           string
 	clients      map[int]*Client
+	spectators   map[int]*game.Spectator
 	lastClientID int
 	TotalClient  int
 	game         *game.Game
+	options      RoomOptions
+	turnTimer    game.Timer
+	turnDeadline time.Time
+	store        RoomStore
+	loggedEvents int
+	onEmpty      func(*Room)
+	emptySince   time.Time
+	lastCommand  time.Time
+	joins        chan joinRequest
+	leaves       chan leaveRequest
+	messages     chan clientMessage
+	calls        chan func()
+	stop         chan struct{}
+	stopped      chan struct{}
 	logger       *logger.Logger
 }
 
-// NewRoom returns a new Room
-func NewRoom(id string, game *game.Game, logger *logger.Logger) *Room {
+type joinRequest struct {
+	client     *Client
+	playerName string
+	token      string
+	password   string
+	spectator  bool
+	result     chan joinResult
+}
+
+type joinResult struct {
+	clientID int
+	err      error
+}
+
+type leaveRequest struct {
+	clientID int
+	client   *Client
+}
+
+type clientMessage struct {
+	clientID int
+	client   *Client
+	data     []byte
+}
+
+// NewRoom returns a new Room, Run must be called to start processing requests
+func NewRoom(id string, g *game.Game, options RoomOptions, store RoomStore, logger *logger.Logger) *Room {
+	now := g.Clock().Now()
 	return &Room{
 		ID:           id,
 		clients:      make(map[int]*Client, 0),
+		spectators:   make(map[int]*game.Spectator),
 		lastClientID: 0,
 		TotalClient:  0,
-		game:         game,
+		game:         g,
+		options:      options,
+		emptySince:   now,
+		lastCommand:  now,
+		store:        store,
+		joins:        make(chan joinRequest),
+		leaves:       make(chan leaveRequest),
+		messages:     make(chan clientMessage),
+		calls:        make(chan func()),
+		stop:         make(chan struct{}),
+		stopped:      make(chan struct{}),
 		logger:       logger,
 	}
 }
 
+// Run processes joins, leaves, messages and calls one at a time until the room is stopped
+func (r *Room) Run() {
+	defer close(r.stopped)
+	for {
+		select {
+		case req := <-r.joins:
+			clientID, err := r.join(req)
+			req.result <- joinResult{clientID: clientID, err: err}
+		case req := <-r.leaves:
+			r.leave(req.clientID, req.client)
+		case msg := <-r.messages:
+			r.handleMessage(msg)
+		case f := <-r.calls:
+			f()
+		case <-r.stop:
+			r.StopTurnTimer()
+			for id, client := range r.clients {
+				client.Close()
+				delete(r.clients, id)
+			}
+			r.TotalClient = 0
+			return
+		}
+	}
+}
+
+// Stop stops the run loop, it must be called from the run loop or once from outside
+func (r *Room) Stop() {
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
+}
+
+// Do runs f in the run loop and waits for it to finish
+// it returns false if the room has been stopped
+func (r *Room) Do(f func()) bool {
+	done := make(chan struct{})
+	select {
+	case r.calls <- func() {
+		defer close(done)
+		f()
+	}:
+	case <-r.stopped:
+		return false
+	}
+	<-done
+	return true
+}
+
+// CanJoin checks if a client can join the room with the given name, session token and password
+func (r *Room) CanJoin(playerName, token, password string, spectator bool) error {
+	var err error
+	if !r.Do(func() {
+		err = r.admit(playerName, token, password, spectator)
+	}) {
+		return ErrRoomClosed
+	}
+	return err
+}
+
 // Join joins the client to the room
-func (r *Room) Join(client *Client) int {
-	r.lastClientID++
-	id := r.lastClientID
-	r.clients[id] = client
-	r.TotalClient++
-	return id
+// a client with the session token of a player is reattached to that player, otherwise a new player is added
+// a spectator joins the room without joining the game
+func (r *Room) Join(client *Client, playerName, token, password string, spectator bool) (int, error) {
+	req := joinRequest{
+		client:     client,
+		playerName: playerName,
+		token:      token,
+		password:   password,
+		spectator:  spectator,
+		result:     make(chan joinResult, 1),
+	}
+	select {
+	case r.joins <- req:
+	case <-r.stopped:
+		return 0, ErrRoomClosed
+	}
+	res := <-req.result
+	return res.clientID, res.err
 }
 
 // Leave leaves the client from the room
-func (r *Room) Leave(clientID int) {
+func (r *Room) Leave(clientID int, client *Client) {
+	select {
+	case r.leaves <- leaveRequest{clientID: clientID, client: client}:
+	case <-r.stopped:
+	}
+}
+
+// Receive passes a message from the client to the room
+func (r *Room) Receive(clientID int, client *Client, data []byte) {
+	select {
+	case r.messages <- clientMessage{clientID: clientID, client: client, data: data}:
+	case <-r.stopped:
+	}
+}
+
+// roomSnapshot represents a room saved in a RoomStore
+type roomSnapshot struct {
+	Options RoomOptions     `json:"options"`
+	Game    json.RawMessage `json:"game"`
+}
+
+// Save appends the new events to the log of the room then saves its snapshot to the store
+func (r *Room) Save() {
+	if r.store == nil {
+		return
+	}
+	if err := r.appendLog(); err != nil {
+		r.logger.Errorf("failed to save the log of room %s: %v", r.ID, err)
+	}
+	g, err := json.Marshal(r.game)
+	if err != nil {
+		r.logger.Error(err)
+		return
+	}
+	data, err := json.Marshal(roomSnapshot{Options: r.options, Game: g})
+	if err != nil {
+		r.logger.Error(err)
+		return
+	}
+	if err := r.store.SaveRoom(r.ID, data); err != nil {
+		r.logger.Errorf("failed to save room %s: %v", r.ID, err)
+	}
+}
+
+// appendLog appends the events that are not in the store yet, one JSON event per line
+func (r *Room) appendLog() error {
+	if r.loggedEvents >= len(r.game.Events) {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, event := range r.game.Events[r.loggedEvents:] {
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+	}
+	if err := r.store.AppendLog(r.ID, buf.Bytes()); err != nil {
+		return err
+	}
+	r.loggedEvents = len(r.game.Events)
+	return nil
+}
+
+// Delete deletes the room from the store
+func (r *Room) Delete() {
+	if r.store == nil {
+		return
+	}
+	if err := r.store.DeleteRoom(r.ID); err != nil {
+		r.logger.Errorf("failed to delete room %s: %v", r.ID, err)
+	}
+}
+
+// admit checks bans and the room options, a player reattaching with a session token skips the options
+func (r *Room) admit(playerName, token, password string, spectator bool) error {
+	if r.game.IsBanned(playerName, token) {
+		return ErrBanned
+	}
+	if !spectator && r.game.PlayerByToken(token) != nil {
+		return nil
+	}
+	if !r.options.CheckPassword(password) {
+		return ErrWrongPassword
+	}
+	if _, action := r.game.FilterText(playerName); action == game.RejectContent {
+		return ErrNameRejected
+	}
+	if spectator {
+		return nil
+	}
+	if r.options.MaxPlayers > 0 && len(r.game.Players) >= r.options.MaxPlayers {
+		return ErrRoomFull
+	}
+	if !r.options.AllowLateJoin && r.game.Phase > game.SubmitPhase {
+		return ErrGameStarted
+	}
+	return nil
+}
+
+func (r *Room) join(req joinRequest) (int, error) {
+	if err := r.admit(req.playerName, req.token, req.password, req.spectator); err != nil {
+		return 0, err
+	}
+	r.emptySince = time.Time{}
+
+	if req.spectator {
+		r.lastClientID++
+		clientID := r.lastClientID
+		name, _ := r.game.FilterText(req.playerName)
+		if name == "" {
+			name = fmt.Sprintf("Spectator %d", clientID)
+		}
+		r.clients[clientID] = req.client
+		r.spectators[clientID] = &game.Spectator{ID: clientID, Name: name}
+		r.TotalClient++
+		r.BroadcastState()
+		return clientID, nil
+	}
+
+	player := r.game.PlayerByToken(req.token)
+	if player == nil && req.playerName == "" {
+		return 0, errors.New("player_name is required")
+	}
+
+	clientID := 0
+	if player != nil {
+		clientID = player.ID
+		if old := r.clients[clientID]; old != nil {
+			old.Close()
+		} else {
+			r.TotalClient++
+		}
+		r.clients[clientID] = req.client
+		r.execSystem("connect_player", &game.PlayerPayload{ID: clientID})
+	} else {
+		r.lastClientID++
+		clientID = r.lastClientID
+		r.clients[clientID] = req.client
+		r.TotalClient++
+		r.execSystem("add_player", &game.AddPlayerPayload{ID: clientID, Name: req.playerName})
+	}
+
+	r.Save()
+	r.BroadcastState()
+	return clientID, nil
+}
+
+func (r *Room) leave(clientID int, client *Client) {
+	if r.clients[clientID] != client {
+		return
+	}
+	client.Close()
 	delete(r.clients, clientID)
 	r.TotalClient--
+	if r.TotalClient == 0 {
+		r.emptySince = r.game.Clock().Now()
+	}
+
+	if _, ok := r.spectators[clientID]; ok {
+		delete(r.spectators, clientID)
+		r.BroadcastState()
+		r.deleteIfEmpty()
+		return
+	}
+
+	r.execSystem("disconnect_player", &game.PlayerPayload{ID: clientID})
+	r.Save()
+	r.BroadcastState()
+	r.ScheduleTurnTimer()
+	r.ScheduleRemoval(clientID)
 }
 
-// WritePump writes messages to the connected clients and checks if any clients are disconnected
-func (r *Room) WritePump(clientID int) {
-	ticker := time.NewTicker(pingPeriod)
-	defer ticker.Stop()
+func (r *Room) handleMessage(m clientMessage) {
+	if r.clients[m.clientID] != m.client {
+		return
+	}
+	if _, ok := r.spectators[m.clientID]; ok {
+		r.sendError(m.client, "", SpectatorErr{clientID: m.clientID})
+		return
+	}
 
-	for {
-		client := r.clients[clientID]
-		if client == nil {
-			break
+	msg := &Message{}
+	err := json.Unmarshal(m.data, msg)
+	if err != nil {
+		r.logger.Error(err)
+		r.sendError(m.client, "", InvalidMessageErr{err: err})
+		return
+	}
+	cmd, err := msg.ToGameCommand(m.clientID)
+	if err != nil {
+		r.logger.Error(err)
+		r.sendError(m.client, msg.RequestID, err)
+		return
+	}
+
+	if err = r.game.ExecCommand(cmd); err != nil {
+		r.logger.Error(err)
+		r.sendError(m.client, msg.RequestID, err)
+		return
+	}
+
+	switch payload := cmd.Payload.(type) {
+	case *game.KickPlayerPayload:
+		r.removeClient(payload.PlayerID, "you have been kicked from the room")
+	case *game.BanPlayerPayload:
+		r.removeClient(payload.PlayerID, "you have been banned from the room")
+	case *game.ExportPayload:
+		r.broadcastExport(payload.Format)
+	}
+
+	r.afterCommand()
+	if msg.RequestID != "" {
+		r.send(m.client, ServerMessage{Type: AckMessageType, RequestID: msg.RequestID})
+	}
+}
+
+// afterCommand saves and broadcasts the game changed by a player's command
+func (r *Room) afterCommand() {
+	r.lastCommand = r.game.Clock().Now()
+	r.Save()
+	r.BroadcastState()
+	r.ScheduleTurnTimer()
+}
+
+// ImportCards imports cards as the player owning the session token and returns the number of imported cards
+func (r *Room) ImportCards(token string, payload *game.ImportCardsPayload) (int, error) {
+	var n int
+	var err error
+	if !r.Do(func() {
+		player := r.game.PlayerByToken(token)
+		if token == "" || player == nil {
+			err = ErrInvalidSessionToken
+			return
 		}
+		before := r.game.DrawPile.Len()
+		cmd := game.Command{Name: "import_cards", PlayerID: player.ID, Payload: payload}
+		if err = r.game.ExecCommand(cmd); err != nil {
+			return
+		}
+		n = r.game.DrawPile.Len() - before
+		r.afterCommand()
+	}) {
+		return 0, ErrRoomClosed
+	}
+	return n, err
+}
 
-		select {
-		case <-ticker.C:
-			client.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				r.logger.Error(err)
-				return
-			}
-		case msgByte := <-client.send:
-			if len(msgByte) == 0 {
-				break
-			}
+// execSystem executes a command issued by the server, it is recorded in the game log like any other command
+func (r *Room) execSystem(name string, payload interface{}) {
+	cmd := game.Command{
+		Name:     name,
+		PlayerID: game.SystemPlayerID,
+		Payload:  payload,
+	}
+	if err := r.game.ExecCommand(cmd); err != nil {
+		r.logger.Error(err)
+	}
+}
 
-			msg := &Message{}
-			err := json.Unmarshal(msgByte, msg)
-			if err != nil {
-				r.logger.Error(err)
-				break
-			}
-			cmd, err := msg.ToGameCommand(clientID)
-			if err != nil {
-				r.logger.Error(err)
-				break
-			}
+// removeClient notifies the client then closes its connection
+func (r *Room) removeClient(clientID int, reason string) {
+	client := r.clients[clientID]
+	if client == nil {
+		return
+	}
+	r.send(client, ServerMessage{Type: RemovedMessageType, Error: reason})
+	client.Close()
+	delete(r.clients, clientID)
+	r.TotalClient--
+}
 
-			if err = r.game.ExecCommand(cmd); err != nil {
-				r.logger.Error(err)
-				break
-			}
+func (r *Room) send(client *Client, msg ServerMessage) {
+	if err := client.SendJSON(msg); err != nil {
+		r.logger.Error(err)
+	}
+}
 
-			r.BroadcastState()
+func (r *Room) sendError(client *Client, requestID string, err error) {
+	r.send(client, ServerMessage{
+		Type:      ErrorMessageType,
+		RequestID: requestID,
+		Code:      errorCode(err),
+		Error:     err.Error(),
+	})
+}
+
+// after runs f in the run loop after the duration d
+func (r *Room) after(d time.Duration, f func()) game.Timer {
+	return r.game.Clock().AfterFunc(d, func() {
+		r.Do(f)
+	})
+}
+
+// ScheduleRemoval removes a disconnected player once the reconnect grace period is over
+func (r *Room) ScheduleRemoval(playerID int) {
+	player := r.game.Players[playerID]
+	if player == nil || player.Connected {
+		return
+	}
+	disconnectedAt := player.DisconnectedAt
+	r.after(disconnectedAt.Add(reconnectGracePeriod).Sub(r.game.Clock().Now()), func() {
+		player := r.game.Players[playerID]
+		if player == nil || player.Connected || !player.DisconnectedAt.Equal(disconnectedAt) {
+			return
 		}
+		r.execSystem("remove_player", &game.RemovePlayerPayload{ID: playerID})
+		r.Save()
+		r.BroadcastState()
+		r.ScheduleTurnTimer()
+		r.deleteIfEmpty()
+	})
+}
+
+// isExpired checks if the room has had no client for longer than emptyTTL or no command for longer than idleTTL
+func (r *Room) isExpired(emptyTTL, idleTTL time.Duration) bool {
+	now := r.game.Clock().Now()
+	if emptyTTL > 0 && r.TotalClient == 0 && now.Sub(r.emptySince) > emptyTTL {
+		return true
 	}
+	return idleTTL > 0 && now.Sub(r.lastCommand) > idleTTL
 }
 
-// BroadcastState broadcasts latest game state to all clients
+// deleteIfEmpty deletes the room once there is no client and no player left
+func (r *Room) deleteIfEmpty() {
+	if r.TotalClient == 0 && len(r.game.Players) == 0 && r.onEmpty != nil {
+		r.onEmpty(r)
+	}
+}
+
+// ScheduleTurnTimer schedules the turn expiry when the turn deadline of the game has changed
+func (r *Room) ScheduleTurnTimer() {
+	deadline := r.game.TurnDeadline
+	if deadline.Equal(r.turnDeadline) {
+		return
+	}
+	r.StopTurnTimer()
+	r.turnDeadline = deadline
+	if deadline.IsZero() {
+		return
+	}
+
+	r.turnTimer = r.after(deadline.Sub(r.game.Clock().Now()), func() {
+		r.expireTurn(deadline)
+	})
+}
+
+// StopTurnTimer stops the scheduled turn expiry
+func (r *Room) StopTurnTimer() {
+	if r.turnTimer != nil {
+		r.turnTimer.Stop()
+		r.turnTimer = nil
+	}
+	r.turnDeadline = time.Time{}
+}
+
+func (r *Room) expireTurn(deadline time.Time) {
+	if !r.game.TurnDeadline.Equal(deadline) {
+		return
+	}
+	r.execSystem("expire_turn", nil)
+	r.Save()
+	r.BroadcastState()
+	r.ScheduleTurnTimer()
+}
+
+// BroadcastState broadcasts latest game state to all players and spectators
 func (r *Room) BroadcastState() {
+	spectators := make([]*game.Spectator, 0, len(r.spectators))
+	for _, spectator := range r.spectators {
+		spectators = append(spectators, spectator)
+	}
+	sort.Slice(spectators, func(i, j int) bool { return spectators[i].ID < spectators[j].ID })
+
 	for _, player := range r.game.Players {
-		state := r.game.State(player.ID)
 		client := r.clients[player.ID]
-		if client != nil {
-			client.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			client.WriteJSON(state)
+		if client == nil {
+			continue
 		}
+		state := r.game.State(player.ID)
+		state.Spectators = spectators
+		r.send(client, ServerMessage{Type: StateMessageType, State: &state})
+	}
+	for id := range r.spectators {
+		state := r.game.SpectatorState(id)
+		state.Spectators = spectators
+		r.send(r.clients[id], ServerMessage{Type: StateMessageType, State: &state})
 	}
 }
 
+// broadcastExport sends the link to download the cards of the room to every client
+func (r *Room) broadcastExport(format string) {
+	url := fmt.Sprintf("/room/%s/export?format=%s", r.ID, format)
+	for _, client := range r.clients {
+		r.send(client, ServerMessage{Type: ExportMessageType, URL: url})
+	}
+}
+
+// Server message types
+const (
+	StateMessageType = "state"
+	AckMessageType   = "ack"
+	ErrorMessageType = "error"
+	// RemovedMessageType is sent before the server closes the connection of a kicked or banned player
+	RemovedMessageType = "removed"
+	// ExportMessageType carries the link to download the cards of the room
+	ExportMessageType = "export"
+)
+
+// ServerMessage represents a message from the server to the websocket client
+type ServerMessage struct {
+	Type      string      `json:"type"`
+	RequestID string      `json:"request_id,omitempty"`
+	Code      string      `json:"code,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	State     *game.State `json:"state,omitempty"`
+	URL       string      `json:"url,omitempty"`
+}
+
+// errorCode returns the code of an error that implements Code, otherwise internal_error
+func errorCode(err error) string {
+	if e, ok := err.(interface{ Code() string }); ok {
+		return e.Code()
+	}
+	return "internal_error"
+}
+
 // Message represents a message from the websocket client
+// the request id is sent back with the ack or the error of the command
 type Message struct {
-	Name    string          `json:"name"`
-	Payload json.RawMessage `json:"payload"`
+	Name      string          `json:"name"`
+	Payload   json.RawMessage `json:"payload"`
+	RequestID string          `json:"request_id"`
 }
 
 // ToGameCommand converts a Message to a Game Command
 func (m Message) ToGameCommand(playerID int) (game.Command, error) {
-	cmd := game.Command{
-		Name:     m.Name,
-		PlayerID: playerID,
-	}
-	var payload interface{}
-	switch cmd.Name {
-	case "set_cards_per_player":
-		payload = &game.SetCardPerPlayerPayload{}
-	case "add_player":
-		payload = &game.AddPlayerPayload{}
-	case "remove_player":
-		payload = &game.RemovePlayerPayload{}
-	case "add_card":
-		payload = &game.AddCardPayload{}
-	case "reset":
-		payload = &game.ResetPayload{}
-	case "start", "draw_card":
-		return cmd, nil
-	default:
-		return cmd, fmt.Errorf("invalid game command: %s", cmd.Name)
-	}
-	err := json.Unmarshal(m.Payload, payload)
-	if err != nil {
-		return cmd, err
-	}
-	cmd.Payload = payload
-	return cmd, nil
+	return game.NewCommand(m.Name, playerID, m.Payload)
+}
+
+// InvalidMessageErr occurs when a message or its payload cannot be decoded
+type InvalidMessageErr struct {
+	err error
+}
+
+func (e InvalidMessageErr) Error() string {
+	return fmt.Sprintf("invalid message: %v", e.err)
+}
+
+func (e InvalidMessageErr) Code() string {
+	return "invalid_message"
+}
+
+// SpectatorErr occurs when a spectator try to execute a game command
+type SpectatorErr struct {
+	clientID int
+}
+
+func (e SpectatorErr) Error() string {
+	return fmt.Sprintf("client %d is a spectator and cannot execute game commands", e.clientID)
+}
+
+func (e SpectatorErr) Code() string {
+	return "spectator"
 }
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+	"whatthecard/pkg/game"
+	"whatthecard/pkg/logger"
+
+	"github.com/gorilla/websocket"
+)
+
+var errConnClosed = errors.New("connection closed")
+
+// fakeConn is a Conn whose incoming messages are sent by the test
+type fakeConn struct {
+	incoming  chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+	mu        sync.Mutex
+	written   int
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		incoming: make(chan []byte),
+		closed:   make(chan struct{}),
+	}
+}
+
+func (c *fakeConn) ReadMessage() (int, []byte, error) {
+	select {
+	case message, ok := <-c.incoming:
+		if !ok {
+			return 0, nil, errConnClosed
+		}
+		return websocket.TextMessage, message, nil
+	case <-c.closed:
+		return 0, nil, errConnClosed
+	}
+}
+
+func (c *fakeConn) WriteMessage(messageType int, data []byte) error {
+	select {
+	case <-c.closed:
+		return errConnClosed
+	default:
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.written++
+	return nil
+}
+
+func (c *fakeConn) SetReadDeadline(t time.Time) error           { return nil }
+func (c *fakeConn) SetWriteDeadline(t time.Time) error          { return nil }
+func (c *fakeConn) SetPongHandler(h func(appData string) error) {}
+
+func (c *fakeConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
+}
+
+func newTestRoom(t *testing.T) *Room {
+	l := logger.NewLogger("")
+	g := game.NewService(game.NewRealClock(), rand.NewSource(1), nil, l).NewGame()
+	room := NewRoom("test", g, DefaultRoomOptions(), nil, l)
+	go room.Run()
+	t.Cleanup(room.Stop)
+	return room
+}
+
+// connect joins a fake client to the room and pumps its messages like HandleWS does
+func connect(room *Room, conn *fakeConn, name, token string, spectator bool) (int, *Client, chan struct{}, error) {
+	client := NewClient(conn, logger.NewLogger(""))
+	clientID, err := room.Join(client, name, token, "", spectator)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	done := make(chan struct{})
+	go client.WritePump()
+	go func() {
+		defer close(done)
+		client.ReadPump(func(message []byte) {
+			room.Receive(clientID, client, message)
+		})
+		room.Leave(clientID, client)
+	}()
+	return clientID, client, done, nil
+}
+
+func TestRoomConcurrentClients(t *testing.T) {
+	const numberOfClients = 50
+	room := newTestRoom(t)
+
+	messages := []string{
+		`{"name":"set_cards_per_player","payload":{"cards_per_player":2}}`,
+		`{"name":"start"}`,
+		`{"name":"add_card","payload":{"text":"card %d"}}`,
+		`{"name":"add_card","payload":{"text":"another card %d"}}`,
+		`{"name":"draw_card"}`,
+		`{"name":"guess_card","request_id":"%d"}`,
+		`{"name":"end_turn"}`,
+		`{"name":"unknown"}`,
+		`not json`,
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < numberOfClients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn := newFakeConn()
+			clientID, _, done, err := connect(room, conn, fmt.Sprintf("player %d", i), "", i%5 == 0)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			for j, message := range messages {
+				conn.incoming <- []byte(fmt.Sprintf(message, i))
+				if j%3 == 0 {
+					room.Do(func() {
+						room.game.State(clientID)
+					})
+				}
+			}
+
+			// a player reconnects with its session token while the old connection is still open
+			var token string
+			room.Do(func() {
+				if player, ok := room.game.Players[clientID]; ok {
+					token = player.Token
+				}
+			})
+			if token != "" {
+				rejoined := newFakeConn()
+				_, _, rejoinedDone, err := connect(room, rejoined, "", token, false)
+				if err != nil {
+					t.Error(err)
+				} else {
+					rejoined.incoming <- []byte(`{"name":"draw_card"}`)
+					close(rejoined.incoming)
+					<-rejoinedDone
+				}
+			}
+
+			close(conn.incoming)
+			<-done
+		}(i)
+	}
+	wg.Wait()
+
+	room.Do(func() {
+		if room.TotalClient != 0 || len(room.clients) != 0 {
+			t.Errorf("got %d clients (total %d), want every client to have left", len(room.clients), room.TotalClient)
+		}
+		for _, player := range room.game.Players {
+			if player.Connected {
+				t.Errorf("player %d is still connected", player.ID)
+			}
+		}
+	})
+}
+
+func TestRoomStopWithConnectedClients(t *testing.T) {
+	room := newTestRoom(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn := newFakeConn()
+			_, _, done, err := connect(room, conn, fmt.Sprintf("player %d", i), "", false)
+			if err == ErrRoomClosed {
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			select {
+			case conn.incoming <- []byte(`{"name":"start"}`):
+			case <-conn.closed:
+			}
+			conn.Close()
+			<-done
+		}(i)
+	}
+	room.Stop()
+	wg.Wait()
+
+	if room.Do(func() {}) {
+		t.Error("Do must return false once the room has been stopped")
+	}
+	if _, err := room.Join(NewClient(newFakeConn(), logger.NewLogger("")), "late", "", "", false); err != ErrRoomClosed {
+		t.Errorf("got %v, want ErrRoomClosed", err)
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Room id defaults
+const (
+	DefaultRoomIDLength   = 4
+	DefaultRoomIDAlphabet = "abcdefghjkmnpqrstuvwxyz"
+)
+
+const (
+	minRoomIDLength    = 3
+	maxRoomIDLength    = 32
+	maxBlockedAttempts = 1000
+)
+
+// DefaultRoomIDBlocklist contains words that must not appear in a generated room id
+var DefaultRoomIDBlocklist = []string{
+	"anal", "anus", "arse", "ass", "butt", "cock", "coon", "crap", "cum", "cunt",
+	"damn", "dick", "dyke", "fag", "fuck", "gook", "hell", "jizz", "kike", "kkk",
+	"nazi", "nig", "piss", "poo", "porn", "puss", "rape", "sex", "shit", "slut",
+	"spic", "tit", "twat", "wank", "whore",
+}
+
+// RoomIDGenerator generates room ids from a cryptographically secure random source
+type RoomIDGenerator struct {
+	length    int
+	alphabet  string
+	blocklist []string
+	reader    io.Reader
+}
+
+// NewRoomIDGenerator returns a new RoomIDGenerator reading from crypto/rand
+// the alphabet must contain unique lowercase letters or digits since room ids are case insensitive
+func NewRoomIDGenerator(length int, alphabet string, blocklist []string) (*RoomIDGenerator, error) {
+	if length < minRoomIDLength || length > maxRoomIDLength {
+		return nil, fmt.Errorf("room id length must be between %d and %d", minRoomIDLength, maxRoomIDLength)
+	}
+	if len(alphabet) < 2 {
+		return nil, errors.New("room id alphabet must have at least 2 characters")
+	}
+	seen := make(map[rune]bool, len(alphabet))
+	for _, c := range alphabet {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9') || seen[c] {
+			return nil, fmt.Errorf("room id alphabet must contain unique lowercase letters or digits, got %q", c)
+		}
+		seen[c] = true
+	}
+
+	words := make([]string, 0, len(blocklist))
+	for _, word := range blocklist {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+
+	return &RoomIDGenerator{
+		length:    length,
+		alphabet:  alphabet,
+		blocklist: words,
+		reader:    rand.Reader,
+	}, nil
+}
+
+// SetReader replaces the random source, it is meant for tests
+func (g *RoomIDGenerator) SetReader(r io.Reader) {
+	g.reader = r
+}
+
+// Generate returns a random room id that contains no blocked word
+func (g *RoomIDGenerator) Generate() (string, error) {
+	for i := 0; i < maxBlockedAttempts; i++ {
+		id, err := g.random()
+		if err != nil {
+			return "", err
+		}
+		if !g.isBlocked(id) {
+			return id, nil
+		}
+	}
+	return "", errors.New("every generated room id contains a blocked word")
+}
+
+// random returns a uniformly random id, bytes that would bias the distribution are discarded
+func (g *RoomIDGenerator) random() (string, error) {
+	n := len(g.alphabet)
+	limit := 256 - 256%n
+	id := make([]byte, 0, g.length)
+	buf := make([]byte, g.length)
+	for len(id) < g.length {
+		if _, err := io.ReadFull(g.reader, buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) >= limit || len(id) == g.length {
+				continue
+			}
+			id = append(id, g.alphabet[int(b)%n])
+		}
+	}
+	return string(id), nil
+}
+
+func (g *RoomIDGenerator) isBlocked(id string) bool {
+	for _, word := range g.blocklist {
+		if strings.Contains(id, word) {
+			return true
+		}
+	}
+	return false
+}
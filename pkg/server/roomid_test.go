@@ -0,0 +1,92 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+)
+
+// zeroReader is a random source that only returns zero bytes
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestNewRoomIDGeneratorRejectsInvalidOptions(t *testing.T) {
+	tests := []struct {
+		length   int
+		alphabet string
+	}{
+		{length: DefaultRoomIDLength, alphabet: ""},
+		{length: DefaultRoomIDLength, alphabet: "a"},
+		{length: DefaultRoomIDLength, alphabet: "aba"},
+		{length: DefaultRoomIDLength, alphabet: "aB"},
+		{length: DefaultRoomIDLength, alphabet: "a-"},
+		{length: minRoomIDLength - 1, alphabet: DefaultRoomIDAlphabet},
+		{length: maxRoomIDLength + 1, alphabet: DefaultRoomIDAlphabet},
+	}
+	for _, tt := range tests {
+		if _, err := NewRoomIDGenerator(tt.length, tt.alphabet, nil); err == nil {
+			t.Errorf("length %d and alphabet %q have been accepted", tt.length, tt.alphabet)
+		}
+	}
+	if _, err := NewRoomIDGenerator(minRoomIDLength, "ab", nil); err != nil {
+		t.Errorf("a 2 character alphabet has been rejected: %v", err)
+	}
+}
+
+func TestRoomIDGeneratorRetriesBlockedIDs(t *testing.T) {
+	ids, err := NewRoomIDGenerator(3, "abc", []string{" AAA "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids.SetReader(bytes.NewReader([]byte{0, 0, 0, 1, 2, 0}))
+	id, err := ids.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "bca" {
+		t.Errorf("got id %q, want %q", id, "bca")
+	}
+}
+
+func TestRoomIDGeneratorFailsWhenEveryIDIsBlocked(t *testing.T) {
+	ids, err := NewRoomIDGenerator(3, "ab", []string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids.SetReader(zeroReader{})
+	if id, err := ids.Generate(); err == nil {
+		t.Errorf("got id %q, want an error", id)
+	}
+}
+
+func TestRoomIDGeneratorDiscardsBiasedBytes(t *testing.T) {
+	// 255 is the only byte above the largest multiple of 3, it would make "a" more likely than "b" and "c"
+	ids, err := NewRoomIDGenerator(3, "abc", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids.SetReader(bytes.NewReader([]byte{255, 3, 255, 255, 4, 254}))
+	id, err := ids.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "abc" {
+		t.Errorf("got id %q, want %q", id, "abc")
+	}
+}
+
+func TestRoomIDGeneratorReturnsReaderErrors(t *testing.T) {
+	ids, err := NewRoomIDGenerator(3, "abc", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids.SetReader(bytes.NewReader([]byte{0, 1}))
+	if id, err := ids.Generate(); err == nil {
+		t.Errorf("got id %q from a short reader, want an error", id)
+	}
+}
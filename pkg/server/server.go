@@ -2,29 +2,42 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"whatthecard/pkg/deck"
 	"whatthecard/pkg/game"
 	"whatthecard/pkg/logger"
 
 	"github.com/gorilla/mux"
 )
 
+// maxDeckSize is the maximum size of an imported deck in bytes
+const maxDeckSize = 1 << 20
+
 // Server represents a server
 type Server struct {
 	r           *mux.Router
 	hub         *Hub
 	gameService *game.Service
+	decks       deck.Store
+	debug       bool
 	logger      *logger.Logger
 }
 
-// New returns a new Server
-func New(hub *Hub, gameService *game.Service, logger *logger.Logger) *Server {
+// New returns a new Server, debug mode allows rooms to be created with a fixed seed
+// the deck library is disabled if decks is nil
+func New(hub *Hub, gameService *game.Service, decks deck.Store, debug bool, logger *logger.Logger) *Server {
 	return &Server{
 		r:           mux.NewRouter(),
 		hub:         hub,
 		gameService: gameService,
+		decks:       decks,
+		debug:       debug,
 		logger:      logger,
 	}
 }
@@ -39,6 +52,15 @@ func (s *Server) Start(addr string) error {
 
 func (s *Server) registerRoutes() {
 	s.r.HandleFunc("/room", s.handleCreateRoom).Methods(http.MethodPost)
+	s.r.HandleFunc("/room/{id}/deck", s.handleImportDeck).Methods(http.MethodPost)
+	s.r.HandleFunc("/room/{id}/export", s.handleExport).Methods(http.MethodGet)
+	s.r.HandleFunc("/room/{id}/summary", s.handleRoomSummary).Methods(http.MethodGet)
+	s.r.HandleFunc("/room/{id}/log", s.handleRoomLog).Methods(http.MethodGet)
+	s.r.HandleFunc("/decks", s.handleListDecks).Methods(http.MethodGet)
+	s.r.HandleFunc("/decks", s.handleCreateDeck).Methods(http.MethodPost)
+	s.r.HandleFunc("/decks/{id}", s.handleGetDeck).Methods(http.MethodGet)
+	s.r.HandleFunc("/decks/{id}", s.handleUpdateDeck).Methods(http.MethodPut)
+	s.r.HandleFunc("/decks/{id}", s.handleDeleteDeck).Methods(http.MethodDelete)
 	s.r.HandleFunc("/ws/room/{id}", s.hub.HandleWS).Methods(http.MethodGet)
 
 	spa := spaHandler{staticPath: "./web/dist", indexPath: "index.html"}
@@ -46,12 +68,271 @@ func (s *Server) registerRoutes() {
 }
 
 func (s *Server) handleCreateRoom(w http.ResponseWriter, r *http.Request) {
-	game := s.gameService.NewGame()
-	room := s.hub.CreateRoom(game, s.logger)
+	req := CreateRoomRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	options, err := req.RoomOptions()
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Seed != nil && !s.debug {
+		writeError(w, "seed is only allowed in debug mode", http.StatusBadRequest)
+		return
+	}
+
+	var d *deck.Deck
+	if req.DeckID != "" {
+		if s.decks == nil {
+			writeError(w, "the deck library is disabled", http.StatusNotImplemented)
+			return
+		}
+		d, err = s.decks.Get(req.DeckID)
+		if err == deck.ErrDeckNotFound {
+			writeError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error(err)
+			writeError(w, "failed to read the deck", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	g := s.gameService.NewGame()
+	if req.Seed != nil {
+		g = s.gameService.NewGameWithSeed(*req.Seed)
+	}
+	if d != nil {
+		// the cards are imported by the server so the import is recorded in the game log
+		data, err := json.Marshal(d.Texts())
+		if err == nil {
+			err = g.ExecCommand(game.Command{
+				Name:     "import_cards",
+				PlayerID: game.SystemPlayerID,
+				Payload:  &game.ImportCardsPayload{Format: game.JSONFormat, Data: string(data)},
+			})
+		}
+		if err != nil {
+			s.logger.Error(err)
+			writeError(w, "failed to import the deck", http.StatusInternalServerError)
+			return
+		}
+	}
+	room, err := s.hub.CreateRoom(g, options, s.logger)
+	if err == ErrTooManyRooms {
+		writeError(w, "the server is full, try again later", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		s.logger.Error(err)
+		writeError(w, "failed to create a room", http.StatusInternalServerError)
+		return
+	}
 
 	writeJSON(w, map[string]interface{}{"room_id": room.ID})
 }
 
+// handleImportDeck imports the cards in the request body to the room, the request is authorized by the host's session token
+// the format is read from the format query or the content type, skip_submit=true starts playing right away
+func (s *Server) handleImportDeck(w http.ResponseWriter, r *http.Request) {
+	room := s.hub.GetRoom(strings.ToLower(mux.Vars(r)["id"]))
+	if room == nil {
+		writeError(w, "room not found", http.StatusNotFound)
+		return
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxDeckSize+1))
+	if err != nil {
+		writeError(w, "failed to read the deck", http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxDeckSize {
+		writeError(w, "the deck is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	token := r.URL.Query().Get("session_token")
+	if token == "" {
+		token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	payload := &game.ImportCardsPayload{
+		Format:     deckFormat(r),
+		Data:       string(data),
+		SkipSubmit: r.URL.Query().Get("skip_submit") == "true",
+	}
+	n, err := room.ImportCards(token, payload)
+	switch err.(type) {
+	case nil:
+	case game.CommandIsForHostOnlyErr:
+		writeError(w, err.Error(), http.StatusForbidden)
+		return
+	case game.InvalidPhaseErr:
+		writeError(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		switch err {
+		case ErrInvalidSessionToken:
+			writeError(w, err.Error(), http.StatusUnauthorized)
+		case ErrRoomClosed:
+			writeError(w, "room not found", http.StatusNotFound)
+		default:
+			writeError(w, "the deck is invalid", http.StatusBadRequest)
+		}
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{"imported": n})
+}
+
+// deckFormat returns the card format of the request
+func deckFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+	contentType := r.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "application/json"):
+		return game.JSONFormat
+	case strings.HasPrefix(contentType, "text/csv"):
+		return game.CSVFormat
+	default:
+		return game.TextFormat
+	}
+}
+
+// RoomExport represents the cards and the draws of a room
+type RoomExport struct {
+	RoomID string               `json:"room_id"`
+	Cards  []*game.ExportedCard `json:"cards"`
+	Draws  []*game.Draw         `json:"draws"`
+}
+
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = game.JSONFormat
+	}
+	if format != game.JSONFormat && format != game.CSVFormat {
+		writeError(w, "format must be json or csv", http.StatusBadRequest)
+		return
+	}
+
+	room := s.hub.GetRoom(strings.ToLower(mux.Vars(r)["id"]))
+	if room == nil {
+		writeError(w, "room not found", http.StatusNotFound)
+		return
+	}
+
+	// the exported cards are built inside the room loop, draws are never changed once resolved
+	var export *RoomExport
+	finished := false
+	ok := room.Do(func() {
+		finished = room.game.Phase == game.FinishedPhase
+		if finished {
+			export = &RoomExport{
+				RoomID: room.ID,
+				Cards:  room.game.ExportCards(),
+				Draws:  append([]*game.Draw{}, room.game.Draws...),
+			}
+		}
+	})
+	if !ok {
+		writeError(w, "room not found", http.StatusNotFound)
+		return
+	}
+	if !finished {
+		writeError(w, "the cards are available once the game has finished", http.StatusConflict)
+		return
+	}
+
+	filename := fmt.Sprintf("room-%s-cards.%s", room.ID, format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	if format == game.CSVFormat {
+		w.Header().Set("Content-Type", "text/csv")
+		if err := game.WriteCardsCSV(w, export.Cards); err != nil {
+			s.logger.Error(err)
+		}
+		return
+	}
+	writeJSON(w, export)
+}
+
+// RoomLog represents the event log of a room, replaying the events on a game with the seed rebuilds the game
+type RoomLog struct {
+	RoomID string        `json:"room_id"`
+	Seed   int64         `json:"seed"`
+	Events []*game.Event `json:"events"`
+}
+
+func (s *Server) handleRoomLog(w http.ResponseWriter, r *http.Request) {
+	room := s.hub.GetRoom(strings.ToLower(mux.Vars(r)["id"]))
+	if room == nil {
+		writeError(w, "room not found", http.StatusNotFound)
+		return
+	}
+
+	var roomLog *RoomLog
+	finished := false
+	room.Do(func() {
+		finished = room.game.Phase == game.FinishedPhase
+		roomLog = &RoomLog{
+			RoomID: room.ID,
+			Seed:   room.game.Seed,
+			Events: append([]*game.Event{}, room.game.Events...),
+		}
+	})
+	if roomLog == nil {
+		writeError(w, "room not found", http.StatusNotFound)
+		return
+	}
+	if !finished {
+		writeError(w, "the log is available once the game has finished", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="room-%s-log.json"`, room.ID))
+	writeJSON(w, roomLog)
+}
+
+func (s *Server) handleRoomSummary(w http.ResponseWriter, r *http.Request) {
+	room := s.hub.GetRoom(strings.ToLower(mux.Vars(r)["id"]))
+	if room == nil {
+		writeError(w, "room not found", http.StatusNotFound)
+		return
+	}
+
+	// the summary shares cards with the game, so it is marshaled inside the room loop
+	var data []byte
+	var err error
+	finished := false
+	ok := room.Do(func() {
+		finished = room.game.Phase == game.FinishedPhase
+		if finished {
+			data, err = json.Marshal(room.game.Summary())
+		}
+	})
+	if !ok {
+		writeError(w, "room not found", http.StatusNotFound)
+		return
+	}
+	if !finished {
+		writeError(w, "the summary is available once the game has finished", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		s.logger.Error(err)
+		writeError(w, "failed to summarize the game", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
 func writeJSON(w http.ResponseWriter, v interface{}) error {
 	w.Header().Set("Content-Type", "application/json")
 	return json.NewEncoder(w).Encode(v)
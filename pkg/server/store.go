@@ -0,0 +1,104 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"whatthecard/pkg/fileutil"
+)
+
+// RoomStore persists snapshots and event logs of rooms so they survive a server restart
+// the snapshot is replaced on every save while the log only grows, so it is appended instead
+type RoomStore interface {
+	SaveRoom(id string, data []byte) error
+	AppendLog(id string, data []byte) error
+	DeleteRoom(id string) error
+	LoadRooms() (map[string][]byte, error)
+	LoadLog(id string) ([]byte, error)
+}
+
+const (
+	roomFileExt = ".json"
+	logFileExt  = ".log"
+)
+
+// FileRoomStore stores each room as a JSON file in a directory
+type FileRoomStore struct {
+	dir string
+}
+
+// NewFileRoomStore returns a new FileRoomStore, the directory is created if it does not exist
+func NewFileRoomStore(dir string) (*FileRoomStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileRoomStore{
+		dir: dir,
+	}, nil
+}
+
+// SaveRoom writes a room snapshot, the previous snapshot is replaced atomically
+func (s *FileRoomStore) SaveRoom(id string, data []byte) error {
+	return fileutil.WriteFileAtomic(s.path(id), data)
+}
+
+// AppendLog appends data to the event log of a room
+func (s *FileRoomStore) AppendLog(id string, data []byte) error {
+	f, err := os.OpenFile(s.logPath(id), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// DeleteRoom deletes a room snapshot and its event log
+func (s *FileRoomStore) DeleteRoom(id string) error {
+	for _, path := range []string{s.path(id), s.logPath(id)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadLog reads the event log of a room, a room without a log has an empty log
+func (s *FileRoomStore) LoadLog(id string) ([]byte, error) {
+	data, err := ioutil.ReadFile(s.logPath(id))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// LoadRooms reads every room snapshot in the directory
+func (s *FileRoomStore) LoadRooms() (map[string][]byte, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	rooms := make(map[string][]byte)
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != roomFileExt {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(s.dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		rooms[strings.TrimSuffix(f.Name(), roomFileExt)] = data
+	}
+	return rooms, nil
+}
+
+func (s *FileRoomStore) path(id string) string {
+	return filepath.Join(s.dir, filepath.Base(id)+roomFileExt)
+}
+
+func (s *FileRoomStore) logPath(id string) string {
+	return filepath.Join(s.dir, filepath.Base(id)+logFileExt)
+}